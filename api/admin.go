@@ -0,0 +1,197 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+	"time"
+)
+
+type CreateClientRequest struct {
+	Name                   string   `json:"name" example:"billing-worker"`
+	Scopes                 []string `json:"scopes" example:"invoices:read,invoices:write"`
+	GrantTypes             []string `json:"grant_types" example:"client_credentials"`
+	RedirectURIs           []string `json:"redirect_uris,omitempty" example:"https://app.example.com/callback"`
+	Audiences              []string `json:"audiences,omitempty" example:"https://billing.example.com"`
+	Public                 bool     `json:"public,omitempty"`
+	AccessTokenTTLSeconds  int      `json:"access_token_ttl_seconds,omitempty" example:"300"`
+	RefreshTokenTTLSeconds int      `json:"refresh_token_ttl_seconds,omitempty"`
+}
+
+type CreateClientResponse struct {
+	ClientID     string `json:"client_id" example:"7d2b1c9e-4f3a-4e8b-9c61-0a5d3e2f1b47"`
+	ClientSecret string `json:"client_secret,omitempty" example:"Jb0n2m8Q..."`
+}
+
+// CreateClient godoc
+// @Summary      Register OAuth client
+// @Description  Register a client; the secret is returned only once. Public clients get no secret
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header  string               true  "Bearer admin_token"
+// @Param        request        body    CreateClientRequest  true  "Client registration"
+// @Success      201  {object}  CreateClientResponse
+// @Failure      400  {string}  string "error(CreateClient):invalid request"
+// @Failure      401  {string}  string "error(CreateClient):unauthorized"
+// @Failure      500  {string}  string "error(CreateClient):register client"
+// @Router       /admin/clients [post]
+func (h *Handler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "error(CreateClient):unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req CreateClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "error(CreateClient):invalid request", http.StatusBadRequest)
+		return
+	}
+	clientID, secret, err := h.service.RegisterClient(r.Context(), service.ClientRegistration{
+		Name:            req.Name,
+		Scopes:          req.Scopes,
+		GrantTypes:      req.GrantTypes,
+		RedirectURIs:    req.RedirectURIs,
+		Audiences:       req.Audiences,
+		Public:          req.Public,
+		AccessTokenTTL:  time.Duration(req.AccessTokenTTLSeconds) * time.Second,
+		RefreshTokenTTL: time.Duration(req.RefreshTokenTTLSeconds) * time.Second,
+	})
+	if errors.Is(err, service.ErrInvalidClient) {
+		http.Error(w, fmt.Sprintf("error(CreateClient):invalid request %v", err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(CreateClient):register client %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CreateClientResponse{ClientID: clientID, ClientSecret: secret}); err != nil {
+		log.Printf("error(CreateClient):failed to write response %v", err)
+	}
+}
+
+func (h *Handler) isAdmin(r *http.Request) bool {
+	token, ok := bearerToken(r)
+	return ok && h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
+
+type SaveRoleRequest struct {
+	Description string   `json:"description" example:"Customer support engineers"`
+	Permissions []string `json:"permissions" example:"tickets:read,tickets:write"`
+}
+
+// SaveRole godoc
+// @Summary      Create or update role
+// @Description  Create the role or replace its permissions. Users get the new permissions in tokens issued after the change
+// @Tags         admin
+// @Accept       json
+// @Param        Authorization  header  string           true  "Bearer admin_token"
+// @Param        name           path    string           true  "Role name"
+// @Param        request        body    SaveRoleRequest  true  "Role"
+// @Success      204  "No Content"
+// @Failure      400  {string}  string "error(SaveRole):invalid request"
+// @Failure      401  {string}  string "error(SaveRole):unauthorized"
+// @Failure      500  {string}  string "error(SaveRole):save role"
+// @Router       /admin/roles/{name} [put]
+func (h *Handler) SaveRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "error(SaveRole):unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req SaveRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "error(SaveRole):invalid request", http.StatusBadRequest)
+		return
+	}
+	err := h.service.SaveRole(r.Context(), r.PathValue("name"), req.Description, req.Permissions)
+	switch {
+	case errors.Is(err, service.ErrInvalidRole):
+		http.Error(w, fmt.Sprintf("error(SaveRole):invalid request %v", err), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(SaveRole):save role %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListRoles godoc
+// @Summary      List roles
+// @Tags         admin
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer admin_token"
+// @Success      200  {array}   models.Role
+// @Failure      401  {string}  string "error(ListRoles):unauthorized"
+// @Failure      500  {string}  string "error(ListRoles):list roles"
+// @Router       /admin/roles [get]
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "error(ListRoles):unauthorized", http.StatusUnauthorized)
+		return
+	}
+	roles, err := h.service.ListRoles(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(ListRoles):list roles %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(roles); err != nil {
+		log.Printf("error(ListRoles):failed to write response %v", err)
+	}
+}
+
+// AssignRole godoc
+// @Summary      Assign role to user
+// @Tags         admin
+// @Param        Authorization  header  string  true  "Bearer admin_token"
+// @Param        id             path    string  true  "User ID"
+// @Param        role           path    string  true  "Role name"
+// @Success      204  "No Content"
+// @Failure      401  {string}  string "error(AssignRole):unauthorized"
+// @Failure      404  {string}  string "error(AssignRole):role not found"
+// @Failure      500  {string}  string "error(AssignRole):assign role"
+// @Router       /admin/users/{id}/roles/{role} [put]
+func (h *Handler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "error(AssignRole):unauthorized", http.StatusUnauthorized)
+		return
+	}
+	err := h.service.AssignRole(r.Context(), r.PathValue("id"), r.PathValue("role"))
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		http.Error(w, "error(AssignRole):role not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(AssignRole):assign role %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UnassignRole godoc
+// @Summary      Remove role from user
+// @Tags         admin
+// @Param        Authorization  header  string  true  "Bearer admin_token"
+// @Param        id             path    string  true  "User ID"
+// @Param        role           path    string  true  "Role name"
+// @Success      204  "No Content"
+// @Failure      401  {string}  string "error(UnassignRole):unauthorized"
+// @Failure      404  {string}  string "error(UnassignRole):role not assigned"
+// @Failure      500  {string}  string "error(UnassignRole):remove role"
+// @Router       /admin/users/{id}/roles/{role} [delete]
+func (h *Handler) UnassignRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "error(UnassignRole):unauthorized", http.StatusUnauthorized)
+		return
+	}
+	err := h.service.UnassignRole(r.Context(), r.PathValue("id"), r.PathValue("role"))
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		http.Error(w, "error(UnassignRole):role not assigned", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(UnassignRole):remove role %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/service"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const authorizeCSRFCookie = "authorize_csrf"
+
+// authorizeParams are the request parameters the login and consent page
+// carries from GET /authorize to POST /authorize.
+var authorizeParams = []string{"response_type", "client_id", "redirect_uri", "scope", "state", "code_challenge", "code_challenge_method", "nonce"}
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.ClientName}}</title>
+<style>body{font-family:sans-serif;max-width:24rem;margin:3rem auto;padding:0 1rem}label,input,button{display:block;width:100%;box-sizing:border-box;margin:.5rem 0}.error{color:#b00020}</style>
+</head>
+<body>
+<h1>Sign in</h1>
+<p><strong>{{.ClientName}}</strong> asks for access to your account{{if .Scopes}} with these permissions:{{end}}</p>
+{{if .Scopes}}<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/authorize">
+{{range .Fields}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+{{if .NeedCode}}<label>Authenticator code <input name="code" autocomplete="one-time-code" inputmode="numeric"></label>
+<label>or recovery code <input name="recovery_code" autocomplete="off"></label>
+{{end}}<button name="decision" value="allow">Sign in and allow</button>
+<button name="decision" value="deny" formnovalidate>Deny</button>
+</form>
+</body>
+</html>
+`))
+
+type authorizePageField struct {
+	Name  string
+	Value string
+}
+
+type authorizePageData struct {
+	ClientName string
+	Scopes     []string
+	Fields     []authorizePageField
+	CSRFToken  string
+	Email      string
+	NeedCode   bool
+	Error      string
+}
+
+// Authorize godoc
+// @Summary      Authorization endpoint (RFC 6749 + PKCE)
+// @Description  Issue a single-use authorization code and redirect back to the client. With a Bearer token the code is issued for that user at once; without one a login and consent page is shown, which posts to POST /authorize. prompt=none redirects with login_required instead of showing the page. PKCE with S256 is mandatory
+// @Tags         oauth
+// @Produce      html
+// @Param        Authorization          header  string  false  "Bearer access_token of the signed-in user"
+// @Param        response_type          query   string  true   "Must be code"  Enums(code)
+// @Param        client_id              query   string  true   "Client ID"
+// @Param        redirect_uri           query   string  true   "Registered redirect URI"
+// @Param        scope                  query   string  false  "Requested scope"
+// @Param        state                  query   string  false  "Opaque value returned to the client"
+// @Param        code_challenge         query   string  true   "PKCE code challenge"
+// @Param        code_challenge_method  query   string  true   "Must be S256"  Enums(S256)
+// @Param        nonce                  query   string  false  "OpenID Connect nonce, echoed in the id_token"
+// @Param        prompt                 query   string  false  "none to fail instead of showing the login page"  Enums(none)
+// @Success      200  "Login and consent page"
+// @Success      302  "Redirect to redirect_uri with code and state, or with error"
+// @Failure      400  {string}  string "error(Authorize):invalid client or redirect_uri"
+// @Router       /authorize [get]
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := authorizeRequestFrom(query)
+	redirect, ok := h.authorizeRedirect(w, r, "Authorize", req, query.Get("state"))
+	if !ok {
+		return
+	}
+	if token, ok := bearerToken(r); ok {
+		code, err := h.service.Authorize(r.Context(), token, req)
+		redirectWithCode(w, r, redirect, code, err)
+		return
+	}
+	if query.Get("prompt") == "none" {
+		redirectWithCode(w, r, redirect, "", service.ErrInvalidToken)
+		return
+	}
+	h.renderAuthorizePage(w, r, redirect, http.StatusOK, query, authorizePageData{})
+}
+
+// AuthorizeSubmit godoc
+// @Summary      Sign in and approve on the authorization page
+// @Description  Form posted by the login and consent page of GET /authorize. Wrong credentials show the page again; a decision redirects to redirect_uri with code or error=access_denied
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        response_type          formData  string  true   "Must be code"
+// @Param        client_id              formData  string  true   "Client ID"
+// @Param        redirect_uri           formData  string  true   "Registered redirect URI"
+// @Param        scope                  formData  string  false  "Requested scope"
+// @Param        state                  formData  string  false  "Opaque value returned to the client"
+// @Param        code_challenge         formData  string  true   "PKCE code challenge"
+// @Param        code_challenge_method  formData  string  true   "Must be S256"
+// @Param        nonce                  formData  string  false  "OpenID Connect nonce"
+// @Param        csrf_token             formData  string  true   "Token from the page, matched against the authorize_csrf cookie"
+// @Param        email                  formData  string  false  "Email"
+// @Param        password               formData  string  false  "Password"
+// @Param        code                   formData  string  false  "TOTP code, for users with two-factor authentication"
+// @Param        recovery_code          formData  string  false  "Recovery code instead of a TOTP code"
+// @Param        decision               formData  string  true   "allow or deny"  Enums(allow, deny)
+// @Success      302  "Redirect to redirect_uri with code and state, or with error"
+// @Failure      400  {string}  string "error(AuthorizeSubmit):invalid client or redirect_uri"
+// @Failure      401  "Login and consent page with an error"
+// @Failure      403  {string}  string "error(AuthorizeSubmit):invalid form token"
+// @Failure      429  "Login and consent page asking to try again later"
+// @Router       /authorize [post]
+func (h *Handler) AuthorizeSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "error(AuthorizeSubmit):invalid request", http.StatusBadRequest)
+		return
+	}
+	form := r.PostForm
+	req := authorizeRequestFrom(form)
+	redirect, ok := h.authorizeRedirect(w, r, "AuthorizeSubmit", req, form.Get("state"))
+	if !ok {
+		return
+	}
+	// The cookie is SameSite=Strict, so a form posted from another site,
+	// e.g. to sign the victim in as the attacker, does not carry it.
+	cookie, err := r.Cookie(authorizeCSRFCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(form.Get("csrf_token"))) != 1 {
+		http.Error(w, "error(AuthorizeSubmit):invalid form token", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: authorizeCSRFCookie, Path: "/authorize", MaxAge: -1})
+	if form.Get("decision") != "allow" {
+		params := redirect.Query()
+		params.Set("error", "access_denied")
+		redirect.RawQuery = params.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+		return
+	}
+	code, err := h.service.AuthorizeWithPassword(r.Context(), form.Get("email"), form.Get("password"), form.Get("code"), form.Get("recovery_code"), req)
+	page := authorizePageData{Email: form.Get("email")}
+	status := http.StatusUnauthorized
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		page.Error = "Wrong email or password."
+	case errors.Is(err, service.ErrMFARequired):
+		page.NeedCode = true
+		page.Error = "Enter the code from your authenticator app or a recovery code."
+	case errors.Is(err, service.ErrInvalidMFACode):
+		page.NeedCode = true
+		page.Error = "Wrong or already used code."
+	case errors.Is(err, service.ErrMFALocked):
+		status = http.StatusTooManyRequests
+		page.NeedCode = true
+		page.Error = "Too many wrong codes. Try again later."
+	default:
+		redirectWithCode(w, r, redirect, code, err)
+		return
+	}
+	h.renderAuthorizePage(w, r, redirect, status, form, page)
+}
+
+func authorizeRequestFrom(values url.Values) service.AuthorizeRequest {
+	return service.AuthorizeRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+		Nonce:               values.Get("nonce"),
+	}
+}
+
+// authorizeRedirect checks the client and redirect_uri and returns the
+// redirect_uri with state set. Until they are known to be good errors are
+// shown to the user instead of being redirected.
+func (h *Handler) authorizeRedirect(w http.ResponseWriter, r *http.Request, op string, req service.AuthorizeRequest, state string) (*url.URL, bool) {
+	err := h.service.ValidateRedirect(r.Context(), req.ClientID, req.RedirectURI)
+	if errors.Is(err, service.ErrInvalidClient) || errors.Is(err, service.ErrInvalidRedirectURI) {
+		http.Error(w, fmt.Sprintf("error(%s):invalid client or redirect_uri", op), http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(%s):validate client %v", op, err), http.StatusInternalServerError)
+		return nil, false
+	}
+	redirect, _ := url.Parse(req.RedirectURI)
+	if state != "" {
+		params := redirect.Query()
+		params.Set("state", state)
+		redirect.RawQuery = params.Encode()
+	}
+	return redirect, true
+}
+
+func redirectWithCode(w http.ResponseWriter, r *http.Request, redirect *url.URL, code string, err error) {
+	params := redirect.Query()
+	if err != nil {
+		params.Set("error", authorizeErrorCode(err))
+	} else {
+		params.Set("code", code)
+	}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// renderAuthorizePage shows the login and consent page for the request in
+// values, or redirects with an error when the request itself is invalid.
+func (h *Handler) renderAuthorizePage(w http.ResponseWriter, r *http.Request, redirect *url.URL, status int, values url.Values, page authorizePageData) {
+	prompt, err := h.service.CheckAuthorizeRequest(r.Context(), authorizeRequestFrom(values))
+	if err != nil {
+		redirectWithCode(w, r, redirect, "", err)
+		return
+	}
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		http.Error(w, "error(Authorize):failed to render page", http.StatusInternalServerError)
+		return
+	}
+	page.CSRFToken = base64.RawURLEncoding.EncodeToString(csrf)
+	page.ClientName = prompt.ClientName
+	page.Scopes = strings.Fields(prompt.Scope)
+	for _, name := range authorizeParams {
+		if value := values.Get(name); value != "" {
+			page.Fields = append(page.Fields, authorizePageField{Name: name, Value: value})
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     authorizeCSRFCookie,
+		Value:    page.CSRFToken,
+		Path:     "/authorize",
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	if err := authorizePage.Execute(w, page); err != nil {
+		log.Printf("error(Authorize):failed to render page %v", err)
+	}
+}
+
+func authorizeErrorCode(err error) string {
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		return "login_required"
+	case errors.Is(err, service.ErrUnsupportedResponseType):
+		return "unsupported_response_type"
+	case errors.Is(err, service.ErrInvalidRequest):
+		return "invalid_request"
+	case errors.Is(err, service.ErrInvalidScope):
+		return "invalid_scope"
+	case errors.Is(err, service.ErrUnauthorizedClient):
+		return "unauthorized_client"
+	}
+	log.Printf("error(Authorize):issue code %v", err)
+	return "server_error"
+}
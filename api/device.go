@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type DeviceDecisionRequest struct {
+	UserCode string `json:"user_code" example:"WDJB-MJHT"`
+	Approve  bool   `json:"approve" example:"true"`
+}
+
+// DeviceCode godoc
+// @Summary      Device authorization request (RFC 8628)
+// @Description  Start a login for a device without a browser. The device shows user_code and polls /oauth/token with the device_code grant
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        client_id  formData  string  true   "Client ID"
+// @Param        scope      formData  string  false  "Requested scope"
+// @Success      200  {object}  models.DeviceAuthorizationResponse
+// @Failure      400  {object}  models.OAuthError
+// @Failure      401  {object}  models.OAuthError
+// @Router       /device/code [post]
+func (h *Handler) DeviceCode(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+	clientID, oerr := h.identifyClient(r)
+	if oerr != nil {
+		writeOAuthError(w, oerr.status, oerr.code, oerr.description)
+		return
+	}
+	if clientID == "" {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication required")
+		return
+	}
+	auth, err := h.service.RequestDeviceCode(r.Context(), clientID, r.PostForm.Get("scope"))
+	if err != nil {
+		oerr := grantError(err)
+		writeOAuthError(w, oerr.status, oerr.code, oerr.description)
+		return
+	}
+	writeJSON(w, models.DeviceAuthorizationResponse{
+		DeviceCode:              auth.DeviceCode,
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		ExpiresIn:               int64(auth.ExpiresIn.Seconds()),
+		Interval:                int64(auth.Interval.Seconds()),
+	})
+}
+
+// DeviceVerification godoc
+// @Summary      Describe a pending device login
+// @Description  Show which client asks for access under user_code, so the signed-in user can decide. Called by the frontend page at DEVICE_VERIFICATION_URL
+// @Tags         oauth
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token of the signed-in user"
+// @Param        user_code      query   string  true  "Code shown on the device"  example("WDJB-MJHT")
+// @Success      200  {object}  models.DeviceVerification
+// @Failure      401  {string}  string "error(DeviceVerification):missing or invalid Authorization header or invalid token"
+// @Failure      404  {string}  string "error(DeviceVerification):unknown or expired user_code"
+// @Failure      429  {string}  string "error(DeviceVerification):too many wrong user codes, try again later"
+// @Router       /device [get]
+func (h *Handler) DeviceVerification(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(DeviceVerification):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	req, err := h.service.LookupDeviceCode(r.Context(), token, r.URL.Query().Get("user_code"), strings.Split(r.RemoteAddr, ":")[0])
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(DeviceVerification):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserCodeNotFound):
+		http.Error(w, "error(DeviceVerification):unknown or expired user_code", http.StatusNotFound)
+	case errors.Is(err, service.ErrTooManyUserCodes):
+		http.Error(w, "error(DeviceVerification):too many wrong user codes, try again later", http.StatusTooManyRequests)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(DeviceVerification):lookup user code %v", err), http.StatusInternalServerError)
+	default:
+		writeJSON(w, models.DeviceVerification{
+			UserCode:   req.UserCode,
+			ClientID:   req.ClientID,
+			ClientName: req.ClientName,
+			Scope:      req.Scope,
+		})
+	}
+}
+
+// DeviceDecision godoc
+// @Summary      Approve or deny a device login
+// @Description  The signed-in user approves (or denies) the device showing user_code; the polling device then receives tokens for this user
+// @Tags         oauth
+// @Accept       json
+// @Param        Authorization  header  string                 true  "Bearer access_token of the signed-in user"
+// @Param        request        body    DeviceDecisionRequest  true  "Decision"
+// @Success      204  "No Content"
+// @Failure      400  {string}  string "error(DeviceDecision):invalid request"
+// @Failure      401  {string}  string "error(DeviceDecision):missing or invalid Authorization header or invalid token"
+// @Failure      404  {string}  string "error(DeviceDecision):unknown or expired user_code"
+// @Failure      429  {string}  string "error(DeviceDecision):too many wrong user codes, try again later"
+// @Router       /device [post]
+func (h *Handler) DeviceDecision(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(DeviceDecision):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	var req DeviceDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserCode == "" {
+		http.Error(w, "error(DeviceDecision):invalid request", http.StatusBadRequest)
+		return
+	}
+	err := h.service.DecideDeviceCode(r.Context(), token, req.UserCode, req.Approve, strings.Split(r.RemoteAddr, ":")[0])
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(DeviceDecision):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserCodeNotFound):
+		http.Error(w, "error(DeviceDecision):unknown or expired user_code", http.StatusNotFound)
+	case errors.Is(err, service.ErrTooManyUserCodes):
+		http.Error(w, "error(DeviceDecision):too many wrong user codes, try again later", http.StatusTooManyRequests)
+	case err != nil:
+		log.Printf("error(DeviceDecision):decide device code %v", err)
+		http.Error(w, "error(DeviceDecision):failed to record decision", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/service"
+	"net/http"
+	"strings"
+)
+
+type EmailRequest struct {
+	Email string `json:"email" example:"user@example.com"`
+}
+
+type EmailTokenRequest struct {
+	Token string `json:"token" example:"Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."`
+}
+
+// LoginEmail godoc
+// @Summary      Request a sign-in link by email
+// @Description  Mail a single-use sign-in link to a registered address. The answer is the same whether or not the address has an account
+// @Tags         users
+// @Accept       json
+// @Param        request body EmailRequest true "Email"
+// @Success      202  "Accepted"
+// @Failure      400  {string}  string "error(LoginEmail):invalid request"
+// @Router       /login/email [post]
+func (h *Handler) LoginEmail(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "error(LoginEmail):invalid request", http.StatusBadRequest)
+		return
+	}
+	h.service.RequestMagicLink(req.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// LoginEmailVerify godoc
+// @Summary      Sign in with an emailed link
+// @Description  Redeem the token from the sign-in link for access and refresh tokens. Users with two-factor authentication get an mfa_token for /login/mfa instead
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request body EmailTokenRequest true "Token from the link"
+// @Success      200  {object}  models.TokenResponse
+// @Success      202  {object}  models.MFAChallengeResponse
+// @Failure      400  {string}  string "error(LoginEmailVerify):invalid request"
+// @Failure      401  {string}  string "error(LoginEmailVerify):invalid or expired link"
+// @Failure      500  {string}  string "error(LoginEmailVerify):generate tokens"
+// @Router       /login/email/verify [post]
+func (h *Handler) LoginEmailVerify(w http.ResponseWriter, r *http.Request) {
+	var req EmailTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "error(LoginEmailVerify):invalid request", http.StatusBadRequest)
+		return
+	}
+	result, err := h.service.RedeemMagicLink(r.Context(), req.Token, service.TokenRequest{
+		UserAgent: r.UserAgent(),
+		IP:        strings.Split(r.RemoteAddr, ":")[0],
+	})
+	if errors.Is(err, service.ErrInvalidEmailToken) {
+		http.Error(w, "error(LoginEmailVerify):invalid or expired link", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(LoginEmailVerify):generate tokens %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeLoginResult(w, "LoginEmailVerify", result)
+}
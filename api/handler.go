@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Tommych123/auth-service/models"
 	"github.com/Tommych123/auth-service/service"
@@ -16,15 +17,18 @@ type RefreshRequest struct {
 }
 
 type MeResponse struct {
-	UserID string `json:"user_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	UserID      string   `json:"user_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Roles       []string `json:"roles" example:"support"`
+	Permissions []string `json:"permissions" example:"tickets:read,tickets:write"`
 }
 
 type Handler struct {
-	service *service.Service
+	service    *service.Service
+	adminToken string
 }
 
-func NewHandler(service *service.Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *service.Service, adminToken string) *Handler {
+	return &Handler{service: service, adminToken: adminToken}
 }
 
 // Token godoc
@@ -34,7 +38,7 @@ func NewHandler(service *service.Service) *Handler {
 // @Accept       json
 // @Produce      json
 // @Param        user_id  query  string  true  "User ID"  example("123e4567-e89b-12d3-a456-426614174000")
-// @Success      200  {object}  auth.TokenResponse
+// @Success      200  {object}  models.TokenResponse
 // @Failure      400  {string}  string "error(Token):missing user_id"
 // @Failure      500  {string}  string "error(Token):generate tokens"
 // @Router       /token [post]
@@ -68,7 +72,7 @@ func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        request body RefreshRequest true "Refresh token request"
-// @Success      200  {object}  TokenResponse
+// @Success      200  {object}  models.TokenResponse
 // @Failure      400  {string}  string "error(Refresh):invalid request"
 // @Failure      401  {string}  string "error(Refresh):unauthorized"
 // @Router       /refresh [post]
@@ -96,58 +100,165 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 }
 
 // Me godoc
-// @Summary      Get current user ID
-// @Description  Get user_id from Authorization Bearer token
+// @Summary      Get current user
+// @Description  Get user_id, roles and permissions of the user owning the Authorization Bearer token
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        Authorization  header  string  true  "Bearer access_token"  example("Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...")
 // @Success      200  {object}  MeResponse
 // @Failure      401  {string}  string "error(Me):missing or invalid Authorization header or invalid token"
+// @Failure      500  {string}  string "error(Me):failed to validate token"
 // @Router       /me [get]
 func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
+	token, ok := bearerToken(r)
+	if !ok {
 		http.Error(w, "error(Me):missing or invalid Authorization header", http.StatusUnauthorized)
 		return
 	}
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	userID, err := h.service.GetUserIDFromToken(token)
-	if err != nil {
+	info, err := h.service.UserInfo(r.Context(), token)
+	if errors.Is(err, service.ErrInvalidToken) {
 		http.Error(w, "error(Me):invalid token", http.StatusUnauthorized)
 		return
 	}
-	if err := json.NewEncoder(w).Encode(MeResponse{UserID: userID}); err != nil {
+	if err != nil {
+		http.Error(w, "error(Me):failed to validate token", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(MeResponse{UserID: info.Sub, Roles: info.Roles, Permissions: info.Permissions}); err != nil {
 		log.Printf("error(Me):failed to write response %v", err)
 	}
 }
 
 // Logout godoc
 // @Summary      Logout user
-// @Description  Deauthorize user by access token
+// @Description  Deauthorize the session of the access token; the access token itself is revoked immediately. scope=all signs out every session of the user
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        Authorization  header  string  true  "Bearer access_token"  example("Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...")
+// @Param        scope          query   string  false  "all to sign out every session"  Enums(all)
 // @Success      200  "OK"
 // @Failure      401  {string}  string "error(Logout):missing or invalid Authorization header or invalid token"
 // @Failure      500  {string}  string "error(Logout):failed to logout"
 // @Router       /logout [post]
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
+	h.logout(w, r, r.URL.Query().Get("scope") == "all")
+}
+
+// LogoutAll godoc
+// @Summary      Logout user everywhere
+// @Description  Deauthorize every session of the user owning the access token
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token"  example("Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...")
+// @Success      200  "OK"
+// @Failure      401  {string}  string "error(Logout):missing or invalid Authorization header or invalid token"
+// @Failure      500  {string}  string "error(Logout):failed to logout"
+// @Router       /logout/all [post]
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	h.logout(w, r, true)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request, allSessions bool) {
+	token, ok := bearerToken(r)
+	if !ok {
 		http.Error(w, "error(Logout):missing or invalid Authorization header", http.StatusUnauthorized)
 		return
 	}
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	userID, err := h.service.GetUserIDFromToken(token)
-	if err != nil {
+	err := h.service.Deauthorize(r.Context(), token, allSessions)
+	if errors.Is(err, service.ErrInvalidToken) {
 		http.Error(w, "error(Logout):invalid token", http.StatusUnauthorized)
 		return
 	}
-	if err := h.service.Deauthorize(r.Context(), userID); err != nil {
+	if err != nil {
 		http.Error(w, "error(Logout):failed to logout", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// Sessions godoc
+// @Summary      List active sessions
+// @Description  Live sessions of the user owning the access token; current marks the session of this token
+// @Tags         sessions
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token"  example("Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...")
+// @Success      200  {array}   models.Session
+// @Failure      401  {string}  string "error(Sessions):missing or invalid Authorization header or invalid token"
+// @Failure      500  {string}  string "error(Sessions):list sessions"
+// @Router       /sessions [get]
+func (h *Handler) Sessions(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(Sessions):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	sessions, err := h.service.ListSessions(r.Context(), token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		http.Error(w, "error(Sessions):invalid token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(Sessions):list sessions %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
+		log.Printf("error(Sessions):failed to write response %v", err)
+	}
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  Sign out one session of the current user, e.g. a lost device; its access token stops working immediately
+// @Tags         sessions
+// @Param        Authorization  header  string  true  "Bearer access_token"  example("Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...")
+// @Param        id             path    string  true  "Session ID from GET /sessions"
+// @Success      204  "No Content"
+// @Failure      401  {string}  string "error(RevokeSession):missing or invalid Authorization header or invalid token"
+// @Failure      404  {string}  string "error(RevokeSession):session not found"
+// @Failure      500  {string}  string "error(RevokeSession):revoke session"
+// @Router       /sessions/{id} [delete]
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(RevokeSession):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	ip := strings.Split(r.RemoteAddr, ":")[0]
+	err := h.service.RevokeSession(r.Context(), token, r.PathValue("id"), ip)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(RevokeSession):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrSessionNotFound):
+		http.Error(w, "error(RevokeSession):session not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(RevokeSession):revoke session %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// JWKS godoc
+// @Summary      Public signing keys
+// @Description  JSON Web Key Set with the public keys used to verify access tokens
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  models.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(h.service.JWKS()); err != nil {
+		log.Printf("error(JWKS):failed to write response %v", err)
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(authHeader, "Bearer "), true
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" example:"Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."`
+	Code         string `json:"code,omitempty" example:"123456"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"ABCD-EFGH-IJKL-MNOP"`
+}
+
+type MFACodeRequest struct {
+	Code         string `json:"code,omitempty" example:"123456"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"ABCD-EFGH-IJKL-MNOP"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/auth.example.com:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=auth.example.com"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// LoginMFA godoc
+// @Summary      Complete login with a second factor
+// @Description  Exchange the mfa_token from /login and a TOTP code (or a one-time recovery code) for access and refresh tokens
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request body MFALoginRequest true "MFA token and code"
+// @Success      200  {object}  models.TokenResponse
+// @Failure      400  {string}  string "error(LoginMFA):invalid request"
+// @Failure      401  {string}  string "error(LoginMFA):invalid code or mfa_token"
+// @Failure      429  {string}  string "error(LoginMFA):too many wrong codes, try again later"
+// @Failure      500  {string}  string "error(LoginMFA):generate tokens"
+// @Router       /login/mfa [post]
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		http.Error(w, "error(LoginMFA):invalid request", http.StatusBadRequest)
+		return
+	}
+	pair, err := h.service.CompleteMFALogin(r.Context(), req.MFAToken, req.Code, req.RecoveryCode, service.TokenRequest{
+		UserAgent: r.UserAgent(),
+		IP:        strings.Split(r.RemoteAddr, ":")[0],
+	})
+	if errors.Is(err, service.ErrInvalidMFACode) || errors.Is(err, service.ErrInvalidMFAChallenge) {
+		http.Error(w, "error(LoginMFA):invalid code or mfa_token", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, service.ErrMFALocked) {
+		http.Error(w, "error(LoginMFA):too many wrong codes, try again later", http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(LoginMFA):generate tokens %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(models.TokenResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}); err != nil {
+		log.Printf("error(LoginMFA):failed to write response %v", err)
+	}
+}
+
+// EnrollTOTP godoc
+// @Summary      Start TOTP enrollment
+// @Description  Generate a TOTP secret for the signed-in user. It protects logins only after /mfa/totp/confirm; calling again replaces an unconfirmed secret
+// @Tags         mfa
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token"
+// @Success      200  {object}  TOTPEnrollResponse
+// @Failure      401  {string}  string "error(EnrollTOTP):missing or invalid Authorization header or invalid token"
+// @Failure      404  {string}  string "error(EnrollTOTP):user has no account"
+// @Failure      409  {string}  string "error(EnrollTOTP):totp already enabled"
+// @Router       /mfa/totp [post]
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(EnrollTOTP):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	enrollment, err := h.service.EnrollTOTP(r.Context(), token)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(EnrollTOTP):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "error(EnrollTOTP):user has no account", http.StatusNotFound)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		http.Error(w, "error(EnrollTOTP):totp already enabled", http.StatusConflict)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(EnrollTOTP):enroll %v", err), http.StatusInternalServerError)
+	default:
+		writeJSON(w, TOTPEnrollResponse{Secret: enrollment.Secret, OTPAuthURI: enrollment.URI})
+	}
+}
+
+// ConfirmTOTP godoc
+// @Summary      Confirm TOTP enrollment
+// @Description  Enable TOTP with the first code from the authenticator app. Returns recovery codes that are shown only once
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header  string          true  "Bearer access_token"
+// @Param        request        body    MFACodeRequest  true  "TOTP code"
+// @Success      200  {object}  RecoveryCodesResponse
+// @Failure      400  {string}  string "error(ConfirmTOTP):invalid request or totp not enrolled"
+// @Failure      401  {string}  string "error(ConfirmTOTP):missing or invalid Authorization header, invalid token or invalid code"
+// @Failure      409  {string}  string "error(ConfirmTOTP):totp already enabled"
+// @Failure      429  {string}  string "error(ConfirmTOTP):too many wrong codes, try again later"
+// @Router       /mfa/totp/confirm [post]
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(ConfirmTOTP):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "error(ConfirmTOTP):invalid request", http.StatusBadRequest)
+		return
+	}
+	codes, err := h.service.ConfirmTOTP(r.Context(), token, req.Code)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(ConfirmTOTP):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrMFANotEnrolled):
+		http.Error(w, "error(ConfirmTOTP):totp not enrolled", http.StatusBadRequest)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		http.Error(w, "error(ConfirmTOTP):totp already enabled", http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidMFACode):
+		http.Error(w, "error(ConfirmTOTP):invalid code", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrMFALocked):
+		http.Error(w, "error(ConfirmTOTP):too many wrong codes, try again later", http.StatusTooManyRequests)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(ConfirmTOTP):enable totp %v", err), http.StatusInternalServerError)
+	default:
+		writeJSON(w, RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// DisableTOTP godoc
+// @Summary      Disable TOTP
+// @Description  Turn off two-factor authentication. Requires a current TOTP code or an unused recovery code
+// @Tags         mfa
+// @Accept       json
+// @Param        Authorization  header  string          true  "Bearer access_token"
+// @Param        request        body    MFACodeRequest  true  "TOTP or recovery code"
+// @Success      204  "Disabled"
+// @Failure      400  {string}  string "error(DisableTOTP):invalid request or totp not enabled"
+// @Failure      401  {string}  string "error(DisableTOTP):missing or invalid Authorization header, invalid token or invalid code"
+// @Failure      429  {string}  string "error(DisableTOTP):too many wrong codes, try again later"
+// @Router       /mfa/totp [delete]
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(DisableTOTP):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "error(DisableTOTP):invalid request", http.StatusBadRequest)
+		return
+	}
+	err := h.service.DisableTOTP(r.Context(), token, req.Code, req.RecoveryCode)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(DisableTOTP):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrMFANotEnrolled):
+		http.Error(w, "error(DisableTOTP):totp not enabled", http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidMFACode):
+		http.Error(w, "error(DisableTOTP):invalid code", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrMFALocked):
+		http.Error(w, "error(DisableTOTP):too many wrong codes, try again later", http.StatusTooManyRequests)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(DisableTOTP):disable totp %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
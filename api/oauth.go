@@ -0,0 +1,393 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type oauthError struct {
+	status      int
+	code        string
+	description string
+}
+
+type grantHandler func(r *http.Request) (models.OAuthTokenResponse, *oauthError)
+
+func (h *Handler) grants() map[string]grantHandler {
+	return map[string]grantHandler{
+		service.GrantRefreshToken:      h.refreshTokenGrant,
+		service.GrantClientCredentials: h.clientCredentialsGrant,
+		service.GrantAuthorizationCode: h.authorizationCodeGrant,
+		service.GrantDeviceCode:        h.deviceCodeGrant,
+		service.GrantTokenExchange:     h.tokenExchangeGrant,
+		service.GrantPassword:          h.passwordGrant,
+	}
+}
+
+// OAuthToken godoc
+// @Summary      OAuth 2.0 token endpoint (RFC 6749)
+// @Description  Issue tokens for the grant named by grant_type. Supported grants: refresh_token, client_credentials, authorization_code, password, urn:ietf:params:oauth:grant-type:device_code, urn:ietf:params:oauth:grant-type:token-exchange
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        grant_type          formData  string  true   "Grant type"  Enums(refresh_token, client_credentials, authorization_code, password, urn:ietf:params:oauth:grant-type:device_code, urn:ietf:params:oauth:grant-type:token-exchange)
+// @Param        refresh_token       formData  string  false  "Refresh token (refresh_token grant)"
+// @Param        scope               formData  string  false  "Requested scope (client_credentials, password and token-exchange grants)"
+// @Param        code                formData  string  false  "Authorization code (authorization_code grant)"
+// @Param        redirect_uri        formData  string  false  "Redirect URI used at /authorize (authorization_code grant)"
+// @Param        code_verifier       formData  string  false  "PKCE code verifier (authorization_code grant)"
+// @Param        device_code         formData  string  false  "Device code from /device/code (device_code grant)"
+// @Param        username            formData  string  false  "User email (password grant)"
+// @Param        password            formData  string  false  "User password (password grant)"
+// @Param        subject_token       formData  string  false  "Access token of the user to act for (token-exchange grant)"
+// @Param        subject_token_type  formData  string  false  "urn:ietf:params:oauth:token-type:access_token or urn:ietf:params:oauth:token-type:jwt (token-exchange grant)"
+// @Param        actor_token         formData  string  false  "Access token of the acting party, defaults to the client (token-exchange grant)"
+// @Param        actor_token_type    formData  string  false  "Type of actor_token (token-exchange grant)"
+// @Param        requested_subject   formData  string  false  "user_id to impersonate; the subject_token user becomes the actor (token-exchange grant)"
+// @Param        audience            formData  string  false  "Intended audience of the issued token (token-exchange grant)"
+// @Param        client_id           formData  string  false  "Client ID of a public client"
+// @Success      200  {object}  models.OAuthTokenResponse
+// @Failure      400  {object}  models.OAuthError
+// @Failure      401  {object}  models.OAuthError
+// @Failure      403  {object}  models.OAuthError
+// @Router       /oauth/token [post]
+func (h *Handler) OAuthToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "error(OAuthToken):method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+	grantType := r.PostForm.Get("grant_type")
+	if grantType == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "missing grant_type")
+		return
+	}
+	grant, ok := h.grants()[grantType]
+	if !ok {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	resp, oerr := grant(r)
+	if oerr != nil {
+		writeOAuthError(w, oerr.status, oerr.code, oerr.description)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (h *Handler) refreshTokenGrant(r *http.Request) (models.OAuthTokenResponse, *oauthError) {
+	refreshToken := r.PostForm.Get("refresh_token")
+	if refreshToken == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusBadRequest, "invalid_request", "missing refresh_token"}
+	}
+	clientID, oerr := h.identifyClient(r)
+	if oerr != nil {
+		return models.OAuthTokenResponse{}, oerr
+	}
+	pair, err := h.service.Refresh(r.Context(), refreshToken, h.tokenRequest(r, clientID))
+	if err != nil {
+		return models.OAuthTokenResponse{}, grantError(err)
+	}
+	return bearerResponse(pair), nil
+}
+
+func (h *Handler) authorizationCodeGrant(r *http.Request) (models.OAuthTokenResponse, *oauthError) {
+	code := r.PostForm.Get("code")
+	if code == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusBadRequest, "invalid_request", "missing code"}
+	}
+	clientID, oerr := h.identifyClient(r)
+	if oerr != nil {
+		return models.OAuthTokenResponse{}, oerr
+	}
+	if clientID == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusUnauthorized, "invalid_client", "client authentication required"}
+	}
+	pair, err := h.service.ExchangeAuthorizationCode(r.Context(), code, clientID, r.PostForm.Get("redirect_uri"),
+		r.PostForm.Get("code_verifier"), h.tokenRequest(r, clientID))
+	if err != nil {
+		return models.OAuthTokenResponse{}, grantError(err)
+	}
+	return bearerResponse(pair), nil
+}
+
+func (h *Handler) deviceCodeGrant(r *http.Request) (models.OAuthTokenResponse, *oauthError) {
+	deviceCode := r.PostForm.Get("device_code")
+	if deviceCode == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusBadRequest, "invalid_request", "missing device_code"}
+	}
+	clientID, oerr := h.identifyClient(r)
+	if oerr != nil {
+		return models.OAuthTokenResponse{}, oerr
+	}
+	if clientID == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusUnauthorized, "invalid_client", "client authentication required"}
+	}
+	pair, err := h.service.ExchangeDeviceCode(r.Context(), deviceCode, clientID, h.tokenRequest(r, clientID))
+	if err != nil {
+		return models.OAuthTokenResponse{}, grantError(err)
+	}
+	return bearerResponse(pair), nil
+}
+
+func (h *Handler) passwordGrant(r *http.Request) (models.OAuthTokenResponse, *oauthError) {
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+	if username == "" || password == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusBadRequest, "invalid_request", "missing username or password"}
+	}
+	clientID, oerr := h.identifyClient(r)
+	if oerr != nil {
+		return models.OAuthTokenResponse{}, oerr
+	}
+	req := h.tokenRequest(r, clientID)
+	req.Scope = r.PostForm.Get("scope")
+	pair, err := h.service.PasswordGrant(r.Context(), username, password, req)
+	if err != nil {
+		return models.OAuthTokenResponse{}, grantError(err)
+	}
+	return bearerResponse(pair), nil
+}
+
+func (h *Handler) tokenExchangeGrant(r *http.Request) (models.OAuthTokenResponse, *oauthError) {
+	if r.PostForm.Get("subject_token") == "" || r.PostForm.Get("subject_token_type") == "" {
+		return models.OAuthTokenResponse{}, &oauthError{http.StatusBadRequest, "invalid_request", "missing subject_token or subject_token_type"}
+	}
+	clientID, oerr := h.authenticateClient(r)
+	if oerr != nil {
+		return models.OAuthTokenResponse{}, oerr
+	}
+	token, err := h.service.ExchangeToken(r.Context(), service.TokenExchangeRequest{
+		ClientID:           clientID,
+		SubjectToken:       r.PostForm.Get("subject_token"),
+		SubjectTokenType:   r.PostForm.Get("subject_token_type"),
+		ActorToken:         r.PostForm.Get("actor_token"),
+		ActorTokenType:     r.PostForm.Get("actor_token_type"),
+		RequestedSubject:   r.PostForm.Get("requested_subject"),
+		RequestedTokenType: r.PostForm.Get("requested_token_type"),
+		Scope:              r.PostForm.Get("scope"),
+		Audience:           r.PostForm.Get("audience"),
+		IP:                 strings.Split(r.RemoteAddr, ":")[0],
+	})
+	if err != nil {
+		return models.OAuthTokenResponse{}, grantError(err)
+	}
+	return models.OAuthTokenResponse{
+		AccessToken:     token.AccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(token.ExpiresIn.Seconds()),
+		Scope:           token.Scope,
+		IssuedTokenType: token.IssuedTokenType,
+	}, nil
+}
+
+func (h *Handler) clientCredentialsGrant(r *http.Request) (models.OAuthTokenResponse, *oauthError) {
+	clientID, oerr := h.authenticateClient(r)
+	if oerr != nil {
+		return models.OAuthTokenResponse{}, oerr
+	}
+	access, ttl, scope, err := h.service.ClientCredentialsToken(r.Context(), clientID, r.PostForm.Get("scope"))
+	if err != nil {
+		return models.OAuthTokenResponse{}, grantError(err)
+	}
+	return models.OAuthTokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func (h *Handler) tokenRequest(r *http.Request, clientID string) service.TokenRequest {
+	return service.TokenRequest{
+		ClientID:  clientID,
+		UserAgent: r.UserAgent(),
+		IP:        strings.Split(r.RemoteAddr, ":")[0],
+	}
+}
+
+func bearerResponse(pair *service.TokenPair) models.OAuthTokenResponse {
+	return models.OAuthTokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(pair.ExpiresIn.Seconds()),
+		RefreshToken: pair.RefreshToken,
+		Scope:        pair.Scope,
+		IDToken:      pair.IDToken,
+	}
+}
+
+func grantError(err error) *oauthError {
+	switch {
+	case errors.Is(err, service.ErrMFARequired):
+		return &oauthError{http.StatusBadRequest, "invalid_grant", "two-factor authentication required, use /login or the authorization code flow"}
+	case errors.Is(err, service.ErrInvalidGrant):
+		return &oauthError{http.StatusBadRequest, "invalid_grant", ""}
+	case errors.Is(err, service.ErrInvalidClient):
+		return &oauthError{http.StatusUnauthorized, "invalid_client", ""}
+	case errors.Is(err, service.ErrUnauthorizedClient):
+		return &oauthError{http.StatusBadRequest, "unauthorized_client", ""}
+	case errors.Is(err, service.ErrInvalidScope):
+		return &oauthError{http.StatusBadRequest, "invalid_scope", ""}
+	case errors.Is(err, service.ErrInvalidTarget):
+		return &oauthError{http.StatusBadRequest, "invalid_target", ""}
+	case errors.Is(err, service.ErrInvalidRequest):
+		return &oauthError{http.StatusBadRequest, "invalid_request", ""}
+	case errors.Is(err, service.ErrExchangeDenied):
+		return &oauthError{http.StatusForbidden, "access_denied", ""}
+	case errors.Is(err, service.ErrAuthorizationPending):
+		return &oauthError{http.StatusBadRequest, "authorization_pending", ""}
+	case errors.Is(err, service.ErrSlowDown):
+		return &oauthError{http.StatusBadRequest, "slow_down", ""}
+	case errors.Is(err, service.ErrAccessDenied):
+		return &oauthError{http.StatusBadRequest, "access_denied", ""}
+	case errors.Is(err, service.ErrExpiredToken):
+		return &oauthError{http.StatusBadRequest, "expired_token", ""}
+	}
+	log.Printf("error(grantError):issue tokens %v", err)
+	return &oauthError{http.StatusInternalServerError, "server_error", ""}
+}
+
+// Introspect godoc
+// @Summary      Token introspection (RFC 7662)
+// @Description  Report whether an access or refresh token is active. Requires registered client authentication via HTTP Basic or client_id/client_secret form fields
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token            formData  string  true   "Token to introspect"
+// @Param        token_type_hint  formData  string  false  "access_token or refresh_token"
+// @Success      200  {object}  models.IntrospectionResponse
+// @Failure      400  {object}  models.OAuthError
+// @Failure      401  {object}  models.OAuthError
+// @Router       /introspect [post]
+func (h *Handler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "error(Introspect):method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+	if _, oerr := h.authenticateClient(r); oerr != nil {
+		writeOAuthError(w, oerr.status, oerr.code, oerr.description)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "missing token")
+		return
+	}
+	resp, err := h.service.Introspect(r.Context(), token, r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		log.Printf("error(Introspect):introspect token %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// Revoke godoc
+// @Summary      Token revocation (RFC 7009)
+// @Description  Revoke a refresh token together with its whole session (every refresh token of the family and their access tokens) or an access token issued to the calling client. Requires registered client authentication via HTTP Basic or client_id/client_secret form fields. Always answers 200 for unknown tokens and tokens of other clients
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Param        token            formData  string  true   "Token to revoke"
+// @Param        token_type_hint  formData  string  false  "access_token or refresh_token"
+// @Success      200  "OK"
+// @Failure      400  {object}  models.OAuthError
+// @Failure      401  {object}  models.OAuthError
+// @Router       /revoke [post]
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "error(Revoke):method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+	clientID, oerr := h.authenticateClient(r)
+	if oerr != nil {
+		writeOAuthError(w, oerr.status, oerr.code, oerr.description)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "missing token")
+		return
+	}
+	if err := h.service.RevokeToken(r.Context(), clientID, token, r.PostForm.Get("token_type_hint")); err != nil {
+		log.Printf("error(Revoke):revoke token %v", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateClient checks HTTP Basic credentials or client_id/client_secret
+// form fields against the registered clients.
+func (h *Handler) authenticateClient(r *http.Request) (string, *oauthError) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	valid, err := h.service.AuthenticateClient(r.Context(), clientID, clientSecret)
+	if err != nil {
+		log.Printf("error(authenticateClient):authenticate client %v", err)
+		return "", &oauthError{http.StatusInternalServerError, "server_error", ""}
+	}
+	if !valid {
+		return "", &oauthError{http.StatusUnauthorized, "invalid_client", "client authentication failed"}
+	}
+	return clientID, nil
+}
+
+// identifyClient authenticates a confidential client when credentials are
+// sent, accepts a bare client_id only from public clients, and returns ""
+// when the request names no client at all.
+func (h *Handler) identifyClient(r *http.Request) (string, *oauthError) {
+	if _, _, ok := r.BasicAuth(); ok || r.PostForm.Get("client_secret") != "" {
+		return h.authenticateClient(r)
+	}
+	clientID := r.PostForm.Get("client_id")
+	if clientID == "" {
+		return "", nil
+	}
+	public, err := h.service.IdentifyPublicClient(r.Context(), clientID)
+	if err != nil {
+		log.Printf("error(identifyClient):identify client %v", err)
+		return "", &oauthError{http.StatusInternalServerError, "server_error", ""}
+	}
+	if !public {
+		return "", &oauthError{http.StatusUnauthorized, "invalid_client", "client authentication failed"}
+	}
+	return clientID, nil
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(models.OAuthError{Error: code, ErrorDescription: description}); err != nil {
+		log.Printf("error(writeOAuthError):failed to write response %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error(writeJSON):failed to write response %v", err)
+	}
+}
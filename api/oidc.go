@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+)
+
+// OpenIDConfiguration godoc
+// @Summary      OpenID Connect discovery document
+// @Description  Served only with an asymmetric JWT_ALGORITHM
+// @Tags         oidc
+// @Produce      json
+// @Success      200  {object}  models.OpenIDConfiguration
+// @Router       /.well-known/openid-configuration [get]
+func (h *Handler) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, h.service.OpenIDConfiguration())
+}
+
+// UserInfo godoc
+// @Summary      OpenID Connect userinfo
+// @Description  Claims about the user owning the Bearer access token. email and email_verified need the email scope unless the token came from /login. Served only with an asymmetric JWT_ALGORITHM
+// @Tags         oidc
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token"  example("Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...")
+// @Success      200  {object}  models.UserInfo
+// @Failure      401  {string}  string "error(UserInfo):missing or invalid Authorization header or invalid token"
+// @Router       /userinfo [get]
+func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		http.Error(w, "error(UserInfo):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	info, err := h.service.UserInfo(r.Context(), token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "error(UserInfo):invalid token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("error(UserInfo):get user info %v", err)
+		http.Error(w, "error(UserInfo):failed to validate token", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, info)
+}
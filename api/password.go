@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/service"
+	"net/http"
+	"strings"
+)
+
+type PasswordResetRequest struct {
+	Token    string `json:"token" example:"Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."`
+	Password string `json:"password" example:"correct horse battery staple"`
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset link
+// @Description  Mail a single-use password reset link to a registered address. The answer is the same whether or not the address has an account
+// @Tags         users
+// @Accept       json
+// @Param        request body EmailRequest true "Email"
+// @Success      202  "Accepted"
+// @Failure      400  {string}  string "error(ForgotPassword):invalid request"
+// @Router       /password/forgot [post]
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "error(ForgotPassword):invalid request", http.StatusBadRequest)
+		return
+	}
+	h.service.RequestPasswordReset(req.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password (8-72 bytes) with the token from a reset link. Every session of the user is revoked
+// @Tags         users
+// @Accept       json
+// @Param        request body PasswordResetRequest true "Token from the link and new password"
+// @Success      204  "Password changed"
+// @Failure      400  {string}  string "error(ResetPassword):invalid request"
+// @Failure      401  {string}  string "error(ResetPassword):invalid or expired link"
+// @Failure      500  {string}  string "error(ResetPassword):reset password"
+// @Router       /password/reset [post]
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "error(ResetPassword):invalid request", http.StatusBadRequest)
+		return
+	}
+	err := h.service.ResetPassword(r.Context(), req.Token, req.Password, strings.Split(r.RemoteAddr, ":")[0])
+	switch {
+	case errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, fmt.Sprintf("error(ResetPassword):invalid request %v", err), http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidEmailToken):
+		http.Error(w, "error(ResetPassword):invalid or expired link", http.StatusUnauthorized)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(ResetPassword):reset password %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SendEmailVerification godoc
+// @Summary      Send an email verification link
+// @Description  Mail a new verification link to the signed-in user. One is sent on registration too
+// @Tags         users
+// @Param        Authorization  header  string  true  "Bearer access_token"
+// @Success      202  "Accepted"
+// @Failure      401  {string}  string "error(SendEmailVerification):missing or invalid Authorization header or invalid token"
+// @Failure      404  {string}  string "error(SendEmailVerification):user has no account"
+// @Failure      409  {string}  string "error(SendEmailVerification):email already verified"
+// @Router       /email/verification [post]
+func (h *Handler) SendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(SendEmailVerification):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	err := h.service.SendEmailVerification(r.Context(), token)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(SendEmailVerification):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "error(SendEmailVerification):user has no account", http.StatusNotFound)
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		http.Error(w, "error(SendEmailVerification):email already verified", http.StatusConflict)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(SendEmailVerification):send link %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// VerifyEmail godoc
+// @Summary      Verify email
+// @Description  Confirm the email address with the token from a verification link
+// @Tags         users
+// @Accept       json
+// @Param        request body EmailTokenRequest true "Token from the link"
+// @Success      204  "Verified"
+// @Failure      400  {string}  string "error(VerifyEmail):invalid request"
+// @Failure      401  {string}  string "error(VerifyEmail):invalid or expired link"
+// @Router       /email/verify [post]
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req EmailTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "error(VerifyEmail):invalid request", http.StatusBadRequest)
+		return
+	}
+	err := h.service.VerifyEmail(r.Context(), req.Token)
+	switch {
+	case errors.Is(err, service.ErrInvalidEmailToken):
+		http.Error(w, "error(VerifyEmail):invalid or expired link", http.StatusUnauthorized)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(VerifyEmail):verify email %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type CredentialsRequest struct {
+	Email    string `json:"email" example:"user@example.com"`
+	Password string `json:"password" example:"correct horse battery staple"`
+}
+
+type RegisterResponse struct {
+	UserID string `json:"user_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+}
+
+// Register godoc
+// @Summary      Register user
+// @Description  Create a user account with email and password (8-72 bytes)
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request body CredentialsRequest true "Email and password"
+// @Success      201  {object}  RegisterResponse
+// @Failure      400  {string}  string "error(Register):invalid request"
+// @Failure      409  {string}  string "error(Register):user already exists"
+// @Failure      500  {string}  string "error(Register):register user"
+// @Router       /register [post]
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req CredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "error(Register):invalid request", http.StatusBadRequest)
+		return
+	}
+	userID, err := h.service.Register(r.Context(), req.Email, req.Password)
+	switch {
+	case errors.Is(err, service.ErrInvalidEmail), errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, fmt.Sprintf("error(Register):invalid request %v", err), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUserExists):
+		http.Error(w, "error(Register):user already exists", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(Register):register user %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(RegisterResponse{UserID: userID}); err != nil {
+		log.Printf("error(Register):failed to write response %v", err)
+	}
+}
+
+// Login godoc
+// @Summary      Login with email and password
+// @Description  Verify the password and issue access and refresh tokens. Users with two-factor authentication get an mfa_token for /login/mfa instead
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request body CredentialsRequest true "Email and password"
+// @Success      200  {object}  models.TokenResponse
+// @Success      202  {object}  models.MFAChallengeResponse
+// @Failure      400  {string}  string "error(Login):invalid request"
+// @Failure      401  {string}  string "error(Login):invalid email or password"
+// @Failure      500  {string}  string "error(Login):generate tokens"
+// @Router       /login [post]
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req CredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "error(Login):invalid request", http.StatusBadRequest)
+		return
+	}
+	result, err := h.service.PasswordLogin(r.Context(), req.Email, req.Password, service.TokenRequest{
+		UserAgent: r.UserAgent(),
+		IP:        strings.Split(r.RemoteAddr, ":")[0],
+	})
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		http.Error(w, "error(Login):invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(Login):generate tokens %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeLoginResult(w, "Login", result)
+}
+
+// writeLoginResult writes the token pair, or the MFA challenge when the user
+// still has to present a second factor.
+func writeLoginResult(w http.ResponseWriter, op string, result *service.LoginResult) {
+	var resp any
+	if result.MFAToken != "" {
+		resp = models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    result.MFAToken,
+			ExpiresIn:   int64(result.MFAExpiresIn.Seconds()),
+			Methods:     []string{"totp", "recovery_code"},
+		}
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		resp = models.TokenResponse{
+			AccessToken:  result.Tokens.AccessToken,
+			RefreshToken: result.Tokens.RefreshToken,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("error(%s):failed to write response %v", op, err)
+	}
+}
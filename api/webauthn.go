@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/service"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// PasskeyRegistrationRequest is the JSON form of the credential returned by
+// navigator.credentials.create (PublicKeyCredential.toJSON). The body is
+// passed to the service as is; the type documents it.
+type PasskeyRegistrationRequest struct {
+	ID       string                     `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	RawID    string                     `json:"rawId" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Type     string                     `json:"type" example:"public-key"`
+	Response AttestationResponseRequest `json:"response"`
+}
+
+type AttestationResponseRequest struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+}
+
+// PasskeyLoginRequest is the JSON form of the credential returned by
+// navigator.credentials.get (PublicKeyCredential.toJSON), passed on as is
+// like PasskeyRegistrationRequest.
+type PasskeyLoginRequest struct {
+	ID       string                   `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	RawID    string                   `json:"rawId" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Type     string                   `json:"type" example:"public-key"`
+	Response AssertionResponseRequest `json:"response"`
+}
+
+type AssertionResponseRequest struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+type PasskeyResponse struct {
+	CredentialID string `json:"credential_id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+}
+
+// PasskeyRegisterBegin godoc
+// @Summary      Start passkey registration
+// @Description  WebAuthn options for navigator.credentials.create. Binary fields are base64url, as expected by PublicKeyCredential.parseCreationOptionsFromJSON. The session must have signed in with a second factor (amr mfa) within the last 10 minutes
+// @Tags         webauthn
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token"
+// @Success      200  {object}  models.PublicKeyCredentialCreationOptions
+// @Failure      401  {string}  string "error(PasskeyRegisterBegin):missing or invalid Authorization header, invalid token or step-up required"
+// @Failure      404  {string}  string "error(PasskeyRegisterBegin):user has no account"
+// @Router       /webauthn/register/begin [post]
+func (h *Handler) PasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(PasskeyRegisterBegin):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	options, err := h.service.BeginPasskeyRegistration(r.Context(), token)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(PasskeyRegisterBegin):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrStepUpRequired):
+		stepUpRequired(w, "PasskeyRegisterBegin")
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "error(PasskeyRegisterBegin):user has no account", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(PasskeyRegisterBegin):create options %v", err), http.StatusInternalServerError)
+	default:
+		writeJSON(w, options)
+	}
+}
+
+// PasskeyRegisterFinish godoc
+// @Summary      Finish passkey registration
+// @Description  Verify the new credential against the challenge from /webauthn/register/begin and store its public key
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header  string                      true  "Bearer access_token"
+// @Param        request        body    PasskeyRegistrationRequest  true  "Credential from navigator.credentials.create"
+// @Success      201  {object}  PasskeyResponse
+// @Failure      400  {string}  string "error(PasskeyRegisterFinish):invalid credential"
+// @Failure      401  {string}  string "error(PasskeyRegisterFinish):missing or invalid Authorization header, invalid token or step-up required"
+// @Failure      409  {string}  string "error(PasskeyRegisterFinish):passkey already registered"
+// @Router       /webauthn/register/finish [post]
+func (h *Handler) PasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(PasskeyRegisterFinish):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	credentialID, err := h.service.FinishPasskeyRegistration(r.Context(), token, r.Body)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(PasskeyRegisterFinish):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrStepUpRequired):
+		stepUpRequired(w, "PasskeyRegisterFinish")
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "error(PasskeyRegisterFinish):user has no account", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidPasskey):
+		http.Error(w, fmt.Sprintf("error(PasskeyRegisterFinish):invalid credential %v", err), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPasskeyExists):
+		http.Error(w, "error(PasskeyRegisterFinish):passkey already registered", http.StatusConflict)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(PasskeyRegisterFinish):register passkey %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(PasskeyResponse{CredentialID: credentialID}); err != nil {
+			log.Printf("error(PasskeyRegisterFinish):failed to write response %v", err)
+		}
+	}
+}
+
+// PasskeyLoginBegin godoc
+// @Summary      Start passkey login
+// @Description  WebAuthn options for navigator.credentials.get. The browser offers every discoverable passkey it holds for this site
+// @Tags         webauthn
+// @Produce      json
+// @Success      200  {object}  models.PublicKeyCredentialRequestOptions
+// @Failure      500  {string}  string "error(PasskeyLoginBegin):create options"
+// @Router       /webauthn/login/begin [post]
+func (h *Handler) PasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	options, err := h.service.BeginPasskeyLogin(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(PasskeyLoginBegin):create options %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, options)
+}
+
+// PasskeyLoginFinish godoc
+// @Summary      Finish passkey login
+// @Description  Verify the assertion against the challenge from /webauthn/login/begin and issue access and refresh tokens
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        request body PasskeyLoginRequest true "Credential from navigator.credentials.get"
+// @Success      200  {object}  models.TokenResponse
+// @Failure      401  {string}  string "error(PasskeyLoginFinish):invalid passkey"
+// @Failure      500  {string}  string "error(PasskeyLoginFinish):generate tokens"
+// @Router       /webauthn/login/finish [post]
+func (h *Handler) PasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	userAgent := r.UserAgent()
+	ip := strings.Split(r.RemoteAddr, ":")[0]
+
+	access, refresh, err := h.service.FinishPasskeyLogin(r.Context(), r.Body, userAgent, ip)
+	if errors.Is(err, service.ErrInvalidPasskey) {
+		http.Error(w, "error(PasskeyLoginFinish):invalid passkey", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(PasskeyLoginFinish):generate tokens %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(models.TokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+	}); err != nil {
+		log.Printf("error(PasskeyLoginFinish):failed to write response %v", err)
+	}
+}
+
+// Passkeys godoc
+// @Summary      List passkeys
+// @Description  Passkeys registered by the user owning the access token
+// @Tags         webauthn
+// @Produce      json
+// @Param        Authorization  header  string  true  "Bearer access_token"
+// @Success      200  {array}   models.Passkey
+// @Failure      401  {string}  string "error(Passkeys):missing or invalid Authorization header or invalid token"
+// @Failure      500  {string}  string "error(Passkeys):list passkeys"
+// @Router       /webauthn/credentials [get]
+func (h *Handler) Passkeys(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(Passkeys):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	passkeys, err := h.service.ListPasskeys(r.Context(), token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		http.Error(w, "error(Passkeys):invalid token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error(Passkeys):list passkeys %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, passkeys)
+}
+
+// DeletePasskey godoc
+// @Summary      Delete a passkey
+// @Description  Remove a passkey of the current user, e.g. a lost authenticator. Needs the same recent second factor as registration
+// @Tags         webauthn
+// @Param        Authorization  header  string  true  "Bearer access_token"
+// @Param        id             path    string  true  "Passkey ID from GET /webauthn/credentials"
+// @Success      204  "No Content"
+// @Failure      401  {string}  string "error(DeletePasskey):missing or invalid Authorization header, invalid token or step-up required"
+// @Failure      404  {string}  string "error(DeletePasskey):passkey not found"
+// @Failure      500  {string}  string "error(DeletePasskey):delete passkey"
+// @Router       /webauthn/credentials/{id} [delete]
+func (h *Handler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "error(DeletePasskey):missing or invalid Authorization header", http.StatusUnauthorized)
+		return
+	}
+	ip := strings.Split(r.RemoteAddr, ":")[0]
+	err := h.service.DeletePasskey(r.Context(), token, r.PathValue("id"), ip)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		http.Error(w, "error(DeletePasskey):invalid token", http.StatusUnauthorized)
+	case errors.Is(err, service.ErrStepUpRequired):
+		stepUpRequired(w, "DeletePasskey")
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrPasskeyNotFound):
+		http.Error(w, "error(DeletePasskey):passkey not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("error(DeletePasskey):delete passkey %v", err), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// stepUpRequired asks the client to sign in again with a second factor
+// (RFC 9470).
+func stepUpRequired(w http.ResponseWriter, name string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_user_authentication", acr_values="`+service.ACRMultiFactor+`"`)
+	http.Error(w, "error("+name+"):sign in again with a second factor", http.StatusUnauthorized)
+}
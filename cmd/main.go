@@ -11,6 +11,7 @@ import (
 	"github.com/Tommych123/auth-service/api"
 	_ "github.com/Tommych123/auth-service/internal/docs"
 	"github.com/Tommych123/auth-service/pkg/db"
+	"github.com/Tommych123/auth-service/pkg/mailer"
 	"github.com/Tommych123/auth-service/repository"
 	"github.com/Tommych123/auth-service/service"
 	"github.com/Tommych123/auth-service/service/config"
@@ -23,7 +24,7 @@ import (
 func enableCors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -38,13 +39,73 @@ func main() {
 	database := db.NewPostgresDB(cfg)
 	sqlxDB := sqlx.NewDb(database, "postgres")
 	repo := repository.NewRepository(sqlxDB)
-	service := service.NewService(repo, cfg.JWTSecret, cfg.WebhookURL)
-	handler := api.NewHandler(service)
+	keys, err := service.LoadKeyRing(cfg.JWTAlgorithm, cfg.JWTSecret, cfg.JWTPrivateKeyPath, cfg.JWTKeyID, cfg.JWTVerifyKeys)
+	if err != nil {
+		log.Fatalf("error(main):of load signing keys: %v", err)
+	}
+	var mail mailer.Mailer
+	switch cfg.MailTransport {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "log":
+		log.Printf("warning(main): MAIL_TRANSPORT=log does not deliver mail, use it only in development")
+		mail = mailer.NewLogMailer(cfg.MailLogPath)
+	}
+	service := service.NewService(repo, keys, mail, cfg)
+	handler := api.NewHandler(service, cfg.AdminToken)
 	mux := http.NewServeMux()
-	mux.HandleFunc("/token", handler.Token)
+	if cfg.LegacyTokenEndpoint {
+		log.Printf("warning(main): LEGACY_TOKEN_ENDPOINT is on, /token issues tokens for any user_id without credentials")
+		mux.HandleFunc("/token", handler.Token)
+	}
+	mux.HandleFunc("POST /register", handler.Register)
+	mux.HandleFunc("POST /login", handler.Login)
+	mux.HandleFunc("POST /login/mfa", handler.LoginMFA)
+	if mail != nil {
+		mux.HandleFunc("POST /login/email", handler.LoginEmail)
+		mux.HandleFunc("POST /login/email/verify", handler.LoginEmailVerify)
+		mux.HandleFunc("POST /password/forgot", handler.ForgotPassword)
+		mux.HandleFunc("POST /password/reset", handler.ResetPassword)
+		mux.HandleFunc("POST /email/verification", handler.SendEmailVerification)
+		mux.HandleFunc("POST /email/verify", handler.VerifyEmail)
+	}
+	mux.HandleFunc("POST /mfa/totp", handler.EnrollTOTP)
+	mux.HandleFunc("POST /mfa/totp/confirm", handler.ConfirmTOTP)
+	mux.HandleFunc("DELETE /mfa/totp", handler.DisableTOTP)
+	mux.HandleFunc("POST /webauthn/register/begin", handler.PasskeyRegisterBegin)
+	mux.HandleFunc("POST /webauthn/register/finish", handler.PasskeyRegisterFinish)
+	mux.HandleFunc("POST /webauthn/login/begin", handler.PasskeyLoginBegin)
+	mux.HandleFunc("POST /webauthn/login/finish", handler.PasskeyLoginFinish)
+	mux.HandleFunc("GET /webauthn/credentials", handler.Passkeys)
+	mux.HandleFunc("DELETE /webauthn/credentials/{id}", handler.DeletePasskey)
 	mux.HandleFunc("/refresh", handler.Refresh)
 	mux.HandleFunc("/me", handler.Me)
 	mux.HandleFunc("/logout", handler.Logout)
+	mux.HandleFunc("/logout/all", handler.LogoutAll)
+	mux.HandleFunc("GET /sessions", handler.Sessions)
+	mux.HandleFunc("DELETE /sessions/{id}", handler.RevokeSession)
+	mux.HandleFunc("/.well-known/jwks.json", handler.JWKS)
+	if service.OIDCEnabled() {
+		mux.HandleFunc("/.well-known/openid-configuration", handler.OpenIDConfiguration)
+		mux.HandleFunc("/userinfo", handler.UserInfo)
+	} else {
+		log.Printf("warning(main): OpenID Connect is off, it needs an asymmetric JWT_ALGORITHM")
+	}
+	mux.HandleFunc("GET /authorize", handler.Authorize)
+	mux.HandleFunc("POST /authorize", handler.AuthorizeSubmit)
+	mux.HandleFunc("/oauth/token", handler.OAuthToken)
+	if cfg.DeviceVerificationURL != "" {
+		mux.HandleFunc("POST /device/code", handler.DeviceCode)
+		mux.HandleFunc("GET /device", handler.DeviceVerification)
+		mux.HandleFunc("POST /device", handler.DeviceDecision)
+	}
+	mux.HandleFunc("/introspect", handler.Introspect)
+	mux.HandleFunc("/revoke", handler.Revoke)
+	mux.HandleFunc("POST /admin/clients", handler.CreateClient)
+	mux.HandleFunc("GET /admin/roles", handler.ListRoles)
+	mux.HandleFunc("PUT /admin/roles/{name}", handler.SaveRole)
+	mux.HandleFunc("PUT /admin/users/{id}/roles/{role}", handler.AssignRole)
+	mux.HandleFunc("DELETE /admin/users/{id}/roles/{role}", handler.UnassignRole)
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
 	addr := fmt.Sprintf(":%s", cfg.Port)
 	log.Println("Server started at http://localhost" + addr)
@@ -15,9 +15,49 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/logout": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "JSON Web Key Set with the public keys used to verify access tokens",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Public signing keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JWKSet"
+                        }
+                    }
+                }
+            }
+        },
+        "/.well-known/openid-configuration": {
+            "get": {
+                "description": "Served only with an asymmetric JWT_ALGORITHM",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "OpenID Connect discovery document",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.OpenIDConfiguration"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/clients": {
             "post": {
-                "description": "Deauthorize user by access token",
+                "description": "Register a client; the secret is returned only once. Public clients get no secret",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,31 +65,48 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Logout user",
+                "summary": "Register OAuth client",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
-                        "description": "Bearer access_token",
+                        "description": "Bearer admin_token",
                         "name": "Authorization",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "description": "Client registration",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CreateClientRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK"
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.CreateClientResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(CreateClient):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
                     "401": {
-                        "description": "error(Logout):missing or invalid Authorization header or invalid token",
+                        "description": "error(CreateClient):unauthorized",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "error(Logout):failed to logout",
+                        "description": "error(CreateClient):register client",
                         "schema": {
                             "type": "string"
                         }
@@ -57,24 +114,19 @@ const docTemplate = `{
                 }
             }
         },
-        "/me": {
+        "/admin/roles": {
             "get": {
-                "description": "Get user_id from Authorization Bearer token",
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Get current user ID",
+                "summary": "List roles",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
-                        "description": "Bearer access_token",
+                        "description": "Bearer admin_token",
                         "name": "Authorization",
                         "in": "header",
                         "required": true
@@ -84,11 +136,20 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/auth.MeResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Role"
+                            }
                         }
                     },
                     "401": {
-                        "description": "error(Me):missing or invalid Authorization header or invalid token",
+                        "description": "error(ListRoles):unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(ListRoles):list roles",
                         "schema": {
                             "type": "string"
                         }
@@ -96,45 +157,59 @@ const docTemplate = `{
                 }
             }
         },
-        "/refresh": {
-            "post": {
-                "description": "Refresh tokens by sending refresh_token and user_id in JSON body",
+        "/admin/roles/{name}": {
+            "put": {
+                "description": "Create the role or replace its permissions. Users get the new permissions in tokens issued after the change",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Refresh access and refresh tokens",
+                "summary": "Create or update role",
                 "parameters": [
                     {
-                        "description": "Refresh token request",
+                        "type": "string",
+                        "description": "Bearer admin_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/auth.RefreshRequest"
+                            "$ref": "#/definitions/api.SaveRoleRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/auth.TokenResponse"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
-                        "description": "error(Refresh):invalid request",
+                        "description": "error(SaveRole):invalid request",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "401": {
-                        "description": "error(Refresh):unauthorized",
+                        "description": "error(SaveRole):unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(SaveRole):save role",
                         "schema": {
                             "type": "string"
                         }
@@ -142,85 +217,2858 @@ const docTemplate = `{
                 }
             }
         },
-        "/token": {
-            "post": {
-                "description": "Generate tokens for a user by user_id query parameter",
-                "consumes": [
-                    "application/json"
+        "/admin/users/{id}/roles/{role}": {
+            "put": {
+                "tags": [
+                    "admin"
                 ],
-                "produces": [
-                    "application/json"
+                "summary": "Assign role to user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer admin_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role name",
+                        "name": "role",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "error(AssignRole):unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(AssignRole):role not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(AssignRole):assign role",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Generate access and refresh tokens",
+                "summary": "Remove role from user",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"123e4567-e89b-12d3-a456-426614174000\"",
+                        "description": "Bearer admin_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
                         "description": "User ID",
-                        "name": "user_id",
-                        "in": "query",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role name",
+                        "name": "role",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "error(UnassignRole):unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/auth.TokenResponse"
+                            "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "error(Token):missing user_id",
+                    "404": {
+                        "description": "error(UnassignRole):role not assigned",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "error(Token):generate tokens",
+                        "description": "error(UnassignRole):remove role",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "auth.MeResponse": {
-            "type": "object",
-            "properties": {
-                "user_id": {
-                    "type": "string",
-                    "example": "123e4567-e89b-12d3-a456-426614174000"
-                }
-            }
         },
-        "auth.RefreshRequest": {
-            "type": "object",
-            "properties": {
-                "refresh_token": {
-                    "type": "string",
-                    "example": "d1a4f8a2c7e9f06..."
-                },
-                "user_id": {
-                    "type": "string",
-                    "example": "123e4567-e89b-12d3-a456-426614174000"
+        "/authorize": {
+            "get": {
+                "description": "Issue a single-use authorization code and redirect back to the client. With a Bearer token the code is issued for that user at once; without one a login and consent page is shown, which posts to POST /authorize. prompt=none redirects with login_required instead of showing the page. PKCE with S256 is mandatory",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Authorization endpoint (RFC 6749 + PKCE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token of the signed-in user",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "enum": [
+                            "code"
+                        ],
+                        "type": "string",
+                        "description": "Must be code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Registered redirect URI",
+                        "name": "redirect_uri",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Requested scope",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque value returned to the client",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code challenge",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "S256"
+                        ],
+                        "type": "string",
+                        "description": "Must be S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OpenID Connect nonce, echoed in the id_token",
+                        "name": "nonce",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "none"
+                        ],
+                        "type": "string",
+                        "description": "none to fail instead of showing the login page",
+                        "name": "prompt",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login and consent page"
+                    },
+                    "302": {
+                        "description": "Redirect to redirect_uri with code and state, or with error"
+                    },
+                    "400": {
+                        "description": "error(Authorize):invalid client or redirect_uri",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
                 }
-            }
-        },
-        "auth.TokenResponse": {
-            "type": "object",
-            "properties": {
-                "access_token": {
-                    "type": "string",
-                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
-                },
-                "refresh_token": {
-                    "type": "string",
-                    "example": "d1a4f8a2c7e9f06..."
+            },
+            "post": {
+                "description": "Form posted by the login and consent page of GET /authorize. Wrong credentials show the page again; a decision redirects to redirect_uri with code or error=access_denied",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Sign in and approve on the authorization page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Must be code",
+                        "name": "response_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Registered redirect URI",
+                        "name": "redirect_uri",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Requested scope",
+                        "name": "scope",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque value returned to the client",
+                        "name": "state",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code challenge",
+                        "name": "code_challenge",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Must be S256",
+                        "name": "code_challenge_method",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OpenID Connect nonce",
+                        "name": "nonce",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token from the page, matched against the authorize_csrf cookie",
+                        "name": "csrf_token",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email",
+                        "name": "email",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "TOTP code, for users with two-factor authentication",
+                        "name": "code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recovery code instead of a TOTP code",
+                        "name": "recovery_code",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "allow",
+                            "deny"
+                        ],
+                        "type": "string",
+                        "description": "allow or deny",
+                        "name": "decision",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to redirect_uri with code and state, or with error"
+                    },
+                    "400": {
+                        "description": "error(AuthorizeSubmit):invalid client or redirect_uri",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Login and consent page with an error"
+                    },
+                    "403": {
+                        "description": "error(AuthorizeSubmit):invalid form token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Login and consent page asking to try again later"
+                    }
+                }
+            }
+        },
+        "/device": {
+            "get": {
+                "description": "Show which client asks for access under user_code, so the signed-in user can decide. Called by the frontend page at DEVICE_VERIFICATION_URL",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Describe a pending device login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token of the signed-in user",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"WDJB-MJHT\"",
+                        "description": "Code shown on the device",
+                        "name": "user_code",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceVerification"
+                        }
+                    },
+                    "401": {
+                        "description": "error(DeviceVerification):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(DeviceVerification):unknown or expired user_code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "error(DeviceVerification):too many wrong user codes, try again later",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "The signed-in user approves (or denies) the device showing user_code; the polling device then receives tokens for this user",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Approve or deny a device login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token of the signed-in user",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Decision",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.DeviceDecisionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "error(DeviceDecision):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(DeviceDecision):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(DeviceDecision):unknown or expired user_code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "error(DeviceDecision):too many wrong user codes, try again later",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/device/code": {
+            "post": {
+                "description": "Start a login for a device without a browser. The device shows user_code and polls /oauth/token with the device_code grant",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Device authorization request (RFC 8628)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Requested scope",
+                        "name": "scope",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceAuthorizationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/verification": {
+            "post": {
+                "description": "Mail a new verification link to the signed-in user. One is sent on registration too",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Send an email verification link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "401": {
+                        "description": "error(SendEmailVerification):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(SendEmailVerification):user has no account",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "error(SendEmailVerification):email already verified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/verify": {
+            "post": {
+                "description": "Confirm the email address with the token from a verification link",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Verify email",
+                "parameters": [
+                    {
+                        "description": "Token from the link",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.EmailTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Verified"
+                    },
+                    "400": {
+                        "description": "error(VerifyEmail):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(VerifyEmail):invalid or expired link",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/introspect": {
+            "post": {
+                "description": "Report whether an access or refresh token is active. Requires registered client authentication via HTTP Basic or client_id/client_secret form fields",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Token introspection (RFC 7662)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token to introspect",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "access_token or refresh_token",
+                        "name": "token_type_hint",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.IntrospectionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Verify the password and issue access and refresh tokens. Users with two-factor authentication get an mfa_token for /login/mfa instead",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Login with email and password",
+                "parameters": [
+                    {
+                        "description": "Email and password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CredentialsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.MFAChallengeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(Login):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(Login):invalid email or password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Login):generate tokens",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/email": {
+            "post": {
+                "description": "Mail a single-use sign-in link to a registered address. The answer is the same whether or not the address has an account",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Request a sign-in link by email",
+                "parameters": [
+                    {
+                        "description": "Email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.EmailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "error(LoginEmail):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/email/verify": {
+            "post": {
+                "description": "Redeem the token from the sign-in link for access and refresh tokens. Users with two-factor authentication get an mfa_token for /login/mfa instead",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Sign in with an emailed link",
+                "parameters": [
+                    {
+                        "description": "Token from the link",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.EmailTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.MFAChallengeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(LoginEmailVerify):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(LoginEmailVerify):invalid or expired link",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(LoginEmailVerify):generate tokens",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/mfa": {
+            "post": {
+                "description": "Exchange the mfa_token from /login and a TOTP code (or a one-time recovery code) for access and refresh tokens",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Complete login with a second factor",
+                "parameters": [
+                    {
+                        "description": "MFA token and code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.MFALoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(LoginMFA):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(LoginMFA):invalid code or mfa_token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "error(LoginMFA):too many wrong codes, try again later",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(LoginMFA):generate tokens",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout": {
+            "post": {
+                "description": "Deauthorize the session of the access token; the access token itself is revoked immediately. scope=all signs out every session of the user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Logout user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "all to sign out every session",
+                        "name": "scope",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "error(Logout):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Logout):failed to logout",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout/all": {
+            "post": {
+                "description": "Deauthorize every session of the user owning the access token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Logout user everywhere",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "error(Logout):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Logout):failed to logout",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/me": {
+            "get": {
+                "description": "Get user_id, roles and permissions of the user owning the Authorization Bearer token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get current user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.MeResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "error(Me):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Me):failed to validate token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/totp": {
+            "post": {
+                "description": "Generate a TOTP secret for the signed-in user. It protects logins only after /mfa/totp/confirm; calling again replaces an unconfirmed secret",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "Start TOTP enrollment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TOTPEnrollResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "error(EnrollTOTP):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(EnrollTOTP):user has no account",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "error(EnrollTOTP):totp already enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Turn off two-factor authentication. Requires a current TOTP code or an unused recovery code",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "Disable TOTP",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "TOTP or recovery code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.MFACodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Disabled"
+                    },
+                    "400": {
+                        "description": "error(DisableTOTP):invalid request or totp not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(DisableTOTP):missing or invalid Authorization header, invalid token or invalid code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "error(DisableTOTP):too many wrong codes, try again later",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/totp/confirm": {
+            "post": {
+                "description": "Enable TOTP with the first code from the authenticator app. Returns recovery codes that are shown only once",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "Confirm TOTP enrollment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "TOTP code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.MFACodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.RecoveryCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(ConfirmTOTP):invalid request or totp not enrolled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(ConfirmTOTP):missing or invalid Authorization header, invalid token or invalid code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "error(ConfirmTOTP):totp already enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "error(ConfirmTOTP):too many wrong codes, try again later",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/token": {
+            "post": {
+                "description": "Issue tokens for the grant named by grant_type. Supported grants: refresh_token, client_credentials, authorization_code, password, urn:ietf:params:oauth:grant-type:device_code, urn:ietf:params:oauth:grant-type:token-exchange",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OAuth 2.0 token endpoint (RFC 6749)",
+                "parameters": [
+                    {
+                        "enum": [
+                            "refresh_token",
+                            "client_credentials",
+                            "authorization_code",
+                            "password",
+                            "urn:ietf:params:oauth:grant-type:device_code",
+                            "urn:ietf:params:oauth:grant-type:token-exchange"
+                        ],
+                        "type": "string",
+                        "description": "Grant type",
+                        "name": "grant_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Refresh token (refresh_token grant)",
+                        "name": "refresh_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Requested scope (client_credentials, password and token-exchange grants)",
+                        "name": "scope",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code (authorization_code grant)",
+                        "name": "code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Redirect URI used at /authorize (authorization_code grant)",
+                        "name": "redirect_uri",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code verifier (authorization_code grant)",
+                        "name": "code_verifier",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device code from /device/code (device_code grant)",
+                        "name": "device_code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User email (password grant)",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password (password grant)",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access token of the user to act for (token-exchange grant)",
+                        "name": "subject_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "urn:ietf:params:oauth:token-type:access_token or urn:ietf:params:oauth:token-type:jwt (token-exchange grant)",
+                        "name": "subject_token_type",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access token of the acting party, defaults to the client (token-exchange grant)",
+                        "name": "actor_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Type of actor_token (token-exchange grant)",
+                        "name": "actor_token_type",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "user_id to impersonate; the subject_token user becomes the actor (token-exchange grant)",
+                        "name": "requested_subject",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Intended audience of the issued token (token-exchange grant)",
+                        "name": "audience",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client ID of a public client",
+                        "name": "client_id",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/forgot": {
+            "post": {
+                "description": "Mail a single-use password reset link to a registered address. The answer is the same whether or not the address has an account",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Request a password reset link",
+                "parameters": [
+                    {
+                        "description": "Email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.EmailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "error(ForgotPassword):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/reset": {
+            "post": {
+                "description": "Set a new password (8-72 bytes) with the token from a reset link. Every session of the user is revoked",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "Token from the link and new password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PasswordResetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Password changed"
+                    },
+                    "400": {
+                        "description": "error(ResetPassword):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(ResetPassword):invalid or expired link",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(ResetPassword):reset password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/refresh": {
+            "post": {
+                "description": "Refresh tokens by sending refresh_token and user_id in JSON body",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh access and refresh tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh token request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(Refresh):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(Refresh):unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/register": {
+            "post": {
+                "description": "Create a user account with email and password (8-72 bytes)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "Email and password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CredentialsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.RegisterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(Register):invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "error(Register):user already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Register):register user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/revoke": {
+            "post": {
+                "description": "Revoke a refresh token together with its whole session (every refresh token of the family and their access tokens) or an access token issued to the calling client. Requires registered client authentication via HTTP Basic or client_id/client_secret form fields. Always answers 200 for unknown tokens and tokens of other clients",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Token revocation (RFC 7009)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token to revoke",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "access_token or refresh_token",
+                        "name": "token_type_hint",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.OAuthError"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions": {
+            "get": {
+                "description": "Live sessions of the user owning the access token; current marks the session of this token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sessions"
+                ],
+                "summary": "List active sessions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Session"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "error(Sessions):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Sessions):list sessions",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{id}": {
+            "delete": {
+                "description": "Sign out one session of the current user, e.g. a lost device; its access token stops working immediately",
+                "tags": [
+                    "sessions"
+                ],
+                "summary": "Revoke a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Session ID from GET /sessions",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "error(RevokeSession):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(RevokeSession):session not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(RevokeSession):revoke session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/token": {
+            "post": {
+                "description": "Generate tokens for a user by user_id query parameter",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Generate access and refresh tokens",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"123e4567-e89b-12d3-a456-426614174000\"",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(Token):missing user_id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Token):generate tokens",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/userinfo": {
+            "get": {
+                "description": "Claims about the user owning the Bearer access token. email and email_verified need the email scope unless the token came from /login. Served only with an asymmetric JWT_ALGORITHM",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "OpenID Connect userinfo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "error(UserInfo):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webauthn/credentials": {
+            "get": {
+                "description": "Passkeys registered by the user owning the access token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "List passkeys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Passkey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "error(Passkeys):missing or invalid Authorization header or invalid token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(Passkeys):list passkeys",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webauthn/credentials/{id}": {
+            "delete": {
+                "description": "Remove a passkey of the current user, e.g. a lost authenticator. Needs the same recent second factor as registration",
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Delete a passkey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Passkey ID from GET /webauthn/credentials",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "error(DeletePasskey):missing or invalid Authorization header, invalid token or step-up required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(DeletePasskey):passkey not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(DeletePasskey):delete passkey",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webauthn/login/begin": {
+            "post": {
+                "description": "WebAuthn options for navigator.credentials.get. The browser offers every discoverable passkey it holds for this site",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Start passkey login",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PublicKeyCredentialRequestOptions"
+                        }
+                    },
+                    "500": {
+                        "description": "error(PasskeyLoginBegin):create options",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webauthn/login/finish": {
+            "post": {
+                "description": "Verify the assertion against the challenge from /webauthn/login/begin and issue access and refresh tokens",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Finish passkey login",
+                "parameters": [
+                    {
+                        "description": "Credential from navigator.credentials.get",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PasskeyLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "error(PasskeyLoginFinish):invalid passkey",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "error(PasskeyLoginFinish):generate tokens",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webauthn/register/begin": {
+            "post": {
+                "description": "WebAuthn options for navigator.credentials.create. Binary fields are base64url, as expected by PublicKeyCredential.parseCreationOptionsFromJSON. The session must have signed in with a second factor (amr mfa) within the last 10 minutes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Start passkey registration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PublicKeyCredentialCreationOptions"
+                        }
+                    },
+                    "401": {
+                        "description": "error(PasskeyRegisterBegin):missing or invalid Authorization header, invalid token or step-up required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "error(PasskeyRegisterBegin):user has no account",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webauthn/register/finish": {
+            "post": {
+                "description": "Verify the new credential against the challenge from /webauthn/register/begin and store its public key",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Finish passkey registration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer access_token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Credential from navigator.credentials.create",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PasskeyRegistrationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.PasskeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "error(PasskeyRegisterFinish):invalid credential",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "error(PasskeyRegisterFinish):missing or invalid Authorization header, invalid token or step-up required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "error(PasskeyRegisterFinish):passkey already registered",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.AssertionResponseRequest": {
+            "type": "object",
+            "properties": {
+                "authenticatorData": {
+                    "type": "string"
+                },
+                "clientDataJSON": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                },
+                "userHandle": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.AttestationResponseRequest": {
+            "type": "object",
+            "properties": {
+                "attestationObject": {
+                    "type": "string"
+                },
+                "clientDataJSON": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.CreateClientRequest": {
+            "type": "object",
+            "properties": {
+                "access_token_ttl_seconds": {
+                    "type": "integer",
+                    "example": 300
+                },
+                "audiences": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://billing.example.com"
+                    ]
+                },
+                "grant_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "client_credentials"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "billing-worker"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://app.example.com/callback"
+                    ]
+                },
+                "refresh_token_ttl_seconds": {
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "invoices:read",
+                        "invoices:write"
+                    ]
+                }
+            }
+        },
+        "api.CreateClientResponse": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string",
+                    "example": "7d2b1c9e-4f3a-4e8b-9c61-0a5d3e2f1b47"
+                },
+                "client_secret": {
+                    "type": "string",
+                    "example": "Jb0n2m8Q..."
+                }
+            }
+        },
+        "api.CredentialsRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "correct horse battery staple"
+                }
+            }
+        },
+        "api.DeviceDecisionRequest": {
+            "type": "object",
+            "properties": {
+                "approve": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "user_code": {
+                    "type": "string",
+                    "example": "WDJB-MJHT"
+                }
+            }
+        },
+        "api.EmailRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                }
+            }
+        },
+        "api.EmailTokenRequest": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "example": "Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."
+                }
+            }
+        },
+        "api.MFACodeRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "recovery_code": {
+                    "type": "string",
+                    "example": "ABCD-EFGH-IJKL-MNOP"
+                }
+            }
+        },
+        "api.MFALoginRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "mfa_token": {
+                    "type": "string",
+                    "example": "Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."
+                },
+                "recovery_code": {
+                    "type": "string",
+                    "example": "ABCD-EFGH-IJKL-MNOP"
+                }
+            }
+        },
+        "api.MeResponse": {
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "tickets:read",
+                        "tickets:write"
+                    ]
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "support"
+                    ]
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                }
+            }
+        },
+        "api.PasskeyLoginRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                },
+                "rawId": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                },
+                "response": {
+                    "$ref": "#/definitions/api.AssertionResponseRequest"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "api.PasskeyRegistrationRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                },
+                "rawId": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                },
+                "response": {
+                    "$ref": "#/definitions/api.AttestationResponseRequest"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "api.PasskeyResponse": {
+            "type": "object",
+            "properties": {
+                "credential_id": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                }
+            }
+        },
+        "api.PasswordResetRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "correct horse battery staple"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."
+                }
+            }
+        },
+        "api.RecoveryCodesResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string",
+                    "example": "d1a4f8a2c7e9f06..."
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                }
+            }
+        },
+        "api.RegisterResponse": {
+            "type": "object",
+            "properties": {
+                "user_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                }
+            }
+        },
+        "api.SaveRoleRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Customer support engineers"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "tickets:read",
+                        "tickets:write"
+                    ]
+                }
+            }
+        },
+        "api.TOTPEnrollResponse": {
+            "type": "object",
+            "properties": {
+                "otpauth_uri": {
+                    "type": "string",
+                    "example": "otpauth://totp/auth.example.com:user@example.com?secret=JBSWY3DPEHPK3PXP\u0026issuer=auth.example.com"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+                }
+            }
+        },
+        "models.Actor": {
+            "type": "object",
+            "properties": {
+                "act": {
+                    "$ref": "#/definitions/models.Actor"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string",
+                    "example": "7d2b1c9e-4f3a-4e8b-9c61-0a5d3e2f1b47"
+                }
+            }
+        },
+        "models.AuthenticatorSelection": {
+            "type": "object",
+            "properties": {
+                "requireResidentKey": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "residentKey": {
+                    "type": "string",
+                    "example": "required"
+                },
+                "userVerification": {
+                    "type": "string",
+                    "example": "required"
+                }
+            }
+        },
+        "models.DeviceAuthorizationResponse": {
+            "type": "object",
+            "properties": {
+                "device_code": {
+                    "type": "string",
+                    "example": "q3Xr8kZ1...IeDx2.Wk9c..."
+                },
+                "expires_in": {
+                    "type": "integer",
+                    "example": 600
+                },
+                "interval": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "user_code": {
+                    "type": "string",
+                    "example": "WDJB-MJHT"
+                },
+                "verification_uri": {
+                    "type": "string",
+                    "example": "https://auth.example.com/device"
+                },
+                "verification_uri_complete": {
+                    "type": "string",
+                    "example": "https://auth.example.com/device?user_code=WDJB-MJHT"
+                }
+            }
+        },
+        "models.DeviceVerification": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string",
+                    "example": "7d2b1c9e-4f3a-4e8b-9c61-0a5d3e2f1b47"
+                },
+                "client_name": {
+                    "type": "string",
+                    "example": "deploy-cli"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "openid"
+                },
+                "user_code": {
+                    "type": "string",
+                    "example": "WDJB-MJHT"
+                }
+            }
+        },
+        "models.IntrospectionResponse": {
+            "type": "object",
+            "properties": {
+                "act": {
+                    "description": "Act identifies the actor of a token issued by token exchange (RFC 8693).",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Actor"
+                        }
+                    ]
+                },
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "aud": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://auth.example.com"
+                    ]
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "exp": {
+                    "type": "integer",
+                    "example": 1735689600
+                },
+                "iat": {
+                    "type": "integer",
+                    "example": 1735689000
+                },
+                "iss": {
+                    "type": "string",
+                    "example": "https://auth.example.com"
+                },
+                "jti": {
+                    "type": "string",
+                    "example": "5f0c6a4e-2b7d-4c39-9a57-3f1b0e8d7c21"
+                },
+                "nbf": {
+                    "type": "integer",
+                    "example": 1735689000
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "access_token"
+                }
+            }
+        },
+        "models.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string",
+                    "example": "RS256"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string",
+                    "example": "AQAB"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string",
+                    "example": "RSA"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string",
+                    "example": "sig"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JWKSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.JWK"
+                    }
+                }
+            }
+        },
+        "models.MFAChallengeResponse": {
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "type": "integer",
+                    "example": 300
+                },
+                "methods": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "totp",
+                        "recovery_code"
+                    ]
+                },
+                "mfa_required": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "mfa_token": {
+                    "type": "string",
+                    "example": "Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."
+                }
+            }
+        },
+        "models.OAuthError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "invalid_request"
+                },
+                "error_description": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.OAuthTokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                },
+                "expires_in": {
+                    "type": "integer",
+                    "example": 600
+                },
+                "id_token": {
+                    "type": "string"
+                },
+                "issued_token_type": {
+                    "description": "IssuedTokenType is set by the token exchange grant only.",
+                    "type": "string",
+                    "example": "urn:ietf:params:oauth:token-type:access_token"
+                },
+                "refresh_token": {
+                    "type": "string",
+                    "example": "d1a4f8a2c7e9f06..."
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "models.OpenIDConfiguration": {
+            "type": "object",
+            "properties": {
+                "authorization_endpoint": {
+                    "type": "string"
+                },
+                "claims_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "code_challenge_methods_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "device_authorization_endpoint": {
+                    "type": "string"
+                },
+                "grant_types_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id_token_signing_alg_values_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "introspection_endpoint": {
+                    "type": "string"
+                },
+                "issuer": {
+                    "type": "string",
+                    "example": "https://auth.example.com"
+                },
+                "jwks_uri": {
+                    "type": "string"
+                },
+                "response_types_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "revocation_endpoint": {
+                    "type": "string"
+                },
+                "scopes_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "subject_types_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token_endpoint": {
+                    "type": "string"
+                },
+                "token_endpoint_auth_methods_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "userinfo_endpoint": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Passkey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                },
+                "last_used_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PublicKeyCredentialCreationOptions": {
+            "type": "object",
+            "properties": {
+                "attestation": {
+                    "type": "string",
+                    "example": "none"
+                },
+                "authenticatorSelection": {
+                    "$ref": "#/definitions/models.AuthenticatorSelection"
+                },
+                "challenge": {
+                    "type": "string",
+                    "example": "kq3X0bZ9c1pN4lq1b2mVtYwG8lK3yZQf7Xh3uO3YvE8"
+                },
+                "excludeCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PublicKeyCredentialDescriptor"
+                    }
+                },
+                "pubKeyCredParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PublicKeyCredentialParameters"
+                    }
+                },
+                "rp": {
+                    "$ref": "#/definitions/models.RelyingParty"
+                },
+                "timeout": {
+                    "type": "integer",
+                    "example": 300000
+                },
+                "user": {
+                    "$ref": "#/definitions/models.PublicKeyCredentialUser"
+                }
+            }
+        },
+        "models.PublicKeyCredentialDescriptor": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "AQIDBAUGBwgJCgsMDQ4PEA"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "models.PublicKeyCredentialParameters": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "integer",
+                    "example": -7
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "models.PublicKeyCredentialRequestOptions": {
+            "type": "object",
+            "properties": {
+                "allowCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PublicKeyCredentialDescriptor"
+                    }
+                },
+                "challenge": {
+                    "type": "string",
+                    "example": "kq3X0bZ9c1pN4lq1b2mVtYwG8lK3yZQf7Xh3uO3YvE8"
+                },
+                "rpId": {
+                    "type": "string",
+                    "example": "auth.example.com"
+                },
+                "timeout": {
+                    "type": "integer",
+                    "example": 300000
+                },
+                "userVerification": {
+                    "type": "string",
+                    "example": "required"
+                }
+            }
+        },
+        "models.PublicKeyCredentialUser": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "MTIzZTQ1NjctZTg5Yi0xMmQzLWE0NTYtNDI2NjE0MTc0MDAw"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "user@example.com"
+                }
+            }
+        },
+        "models.RelyingParty": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "auth.example.com"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Auth Service"
+                }
+            }
+        },
+        "models.Role": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Customer support engineers"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "support"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "tickets:read",
+                        "tickets:write"
+                    ]
+                }
+            }
+        },
+        "models.Session": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5f0c6a4e-2b7d-4c39-9a57-3f1b0e8d7c21"
+                },
+                "ip_address": {
+                    "type": "string",
+                    "example": "192.168.1.123"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string",
+                    "example": "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5)"
+                }
+            }
+        },
+        "models.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                },
+                "refresh_token": {
+                    "type": "string",
+                    "example": "d1a4f8a2c7e9f06..."
+                }
+            }
+        },
+        "models.UserInfo": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "email_verified": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "tickets:read",
+                        "tickets:write"
+                    ]
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "support"
+                    ]
+                },
+                "sub": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
                 }
             }
         }
@@ -1,7 +1,203 @@
 package models
 
+import "time"
+
 // swagger:model TokenResponse
 type TokenResponse struct {
 	AccessToken  string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
 	RefreshToken string `json:"refresh_token" example:"d1a4f8a2c7e9f06..."`
 }
+
+// swagger:model MFAChallengeResponse
+type MFAChallengeResponse struct {
+	MFARequired bool     `json:"mfa_required" example:"true"`
+	MFAToken    string   `json:"mfa_token" example:"Yk9x0dQ2pZ1m.Hq4k1YzZk2b0T8nV..."`
+	ExpiresIn   int64    `json:"expires_in" example:"300"`
+	Methods     []string `json:"methods" example:"totp,recovery_code"`
+}
+
+// swagger:model OAuthTokenResponse
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"600"`
+	RefreshToken string `json:"refresh_token,omitempty" example:"d1a4f8a2c7e9f06..."`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// IssuedTokenType is set by the token exchange grant only.
+	IssuedTokenType string `json:"issued_token_type,omitempty" example:"urn:ietf:params:oauth:token-type:access_token"`
+}
+
+// swagger:model JWK
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`
+	Use string `json:"use,omitempty" example:"sig"`
+	Alg string `json:"alg,omitempty" example:"RS256"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty" example:"AQAB"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// swagger:model JWKSet
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// swagger:model Session
+type Session struct {
+	ID         string    `json:"id" example:"5f0c6a4e-2b7d-4c39-9a57-3f1b0e8d7c21"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5)"`
+	IPAddress  string    `json:"ip_address" example:"192.168.1.123"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// swagger:model IntrospectionResponse
+type IntrospectionResponse struct {
+	Active    bool     `json:"active" example:"true"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Sub       string   `json:"sub,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Aud       []string `json:"aud,omitempty" example:"https://auth.example.com"`
+	Iss       string   `json:"iss,omitempty" example:"https://auth.example.com"`
+	TokenType string   `json:"token_type,omitempty" example:"access_token"`
+	Exp       int64    `json:"exp,omitempty" example:"1735689600"`
+	Iat       int64    `json:"iat,omitempty" example:"1735689000"`
+	Nbf       int64    `json:"nbf,omitempty" example:"1735689000"`
+	Jti       string   `json:"jti,omitempty" example:"5f0c6a4e-2b7d-4c39-9a57-3f1b0e8d7c21"`
+	// Act identifies the actor of a token issued by token exchange (RFC 8693).
+	Act *Actor `json:"act,omitempty"`
+}
+
+// swagger:model Actor
+type Actor struct {
+	Sub      string `json:"sub" example:"7d2b1c9e-4f3a-4e8b-9c61-0a5d3e2f1b47"`
+	ClientID string `json:"client_id,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
+}
+
+// swagger:model OAuthError
+type OAuthError struct {
+	Error            string `json:"error" example:"invalid_request"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// swagger:model UserInfo
+type UserInfo struct {
+	Sub           string   `json:"sub" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Email         string   `json:"email,omitempty" example:"user@example.com"`
+	EmailVerified *bool    `json:"email_verified,omitempty" example:"true"`
+	Roles         []string `json:"roles,omitempty" example:"support"`
+	Permissions   []string `json:"permissions,omitempty" example:"tickets:read,tickets:write"`
+}
+
+// swagger:model Role
+type Role struct {
+	Name        string   `json:"name" example:"support"`
+	Description string   `json:"description,omitempty" example:"Customer support engineers"`
+	Permissions []string `json:"permissions" example:"tickets:read,tickets:write"`
+}
+
+// swagger:model DeviceAuthorizationResponse
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code" example:"q3Xr8kZ1...IeDx2.Wk9c..."`
+	UserCode                string `json:"user_code" example:"WDJB-MJHT"`
+	VerificationURI         string `json:"verification_uri" example:"https://auth.example.com/device"`
+	VerificationURIComplete string `json:"verification_uri_complete" example:"https://auth.example.com/device?user_code=WDJB-MJHT"`
+	ExpiresIn               int64  `json:"expires_in" example:"600"`
+	Interval                int64  `json:"interval" example:"5"`
+}
+
+// swagger:model DeviceVerification
+type DeviceVerification struct {
+	UserCode   string `json:"user_code" example:"WDJB-MJHT"`
+	ClientID   string `json:"client_id" example:"7d2b1c9e-4f3a-4e8b-9c61-0a5d3e2f1b47"`
+	ClientName string `json:"client_name" example:"deploy-cli"`
+	Scope      string `json:"scope,omitempty" example:"openid"`
+}
+
+// swagger:model OpenIDConfiguration
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer" example:"https://auth.example.com"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// PublicKeyCredentialCreationOptions is the JSON form of the WebAuthn
+// options for navigator.credentials.create; binary fields are base64url.
+// swagger:model PublicKeyCredentialCreationOptions
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                          `json:"challenge" example:"kq3X0bZ9c1pN4lq1b2mVtYwG8lK3yZQf7Xh3uO3YvE8"`
+	RP                     RelyingParty                    `json:"rp"`
+	User                   PublicKeyCredentialUser         `json:"user"`
+	PubKeyCredParams       []PublicKeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout" example:"300000"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection          `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation" example:"none"`
+}
+
+// PublicKeyCredentialRequestOptions is the JSON form of the WebAuthn options
+// for navigator.credentials.get.
+// swagger:model PublicKeyCredentialRequestOptions
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge" example:"kq3X0bZ9c1pN4lq1b2mVtYwG8lK3yZQf7Xh3uO3YvE8"`
+	Timeout          int64                           `json:"timeout" example:"300000"`
+	RPID             string                          `json:"rpId" example:"auth.example.com"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification" example:"required"`
+}
+
+type RelyingParty struct {
+	ID   string `json:"id" example:"auth.example.com"`
+	Name string `json:"name" example:"Auth Service"`
+}
+
+type PublicKeyCredentialUser struct {
+	ID          string `json:"id" example:"MTIzZTQ1NjctZTg5Yi0xMmQzLWE0NTYtNDI2NjE0MTc0MDAw"`
+	Name        string `json:"name" example:"user@example.com"`
+	DisplayName string `json:"displayName" example:"user@example.com"`
+}
+
+type PublicKeyCredentialParameters struct {
+	Type string `json:"type" example:"public-key"`
+	Alg  int64  `json:"alg" example:"-7"`
+}
+
+type PublicKeyCredentialDescriptor struct {
+	Type string `json:"type" example:"public-key"`
+	ID   string `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey" example:"required"`
+	RequireResidentKey bool   `json:"requireResidentKey" example:"true"`
+	UserVerification   string `json:"userVerification" example:"required"`
+}
+
+// Passkey is a registered WebAuthn credential; last_used_at is absent until
+// the first login with it.
+// swagger:model Passkey
+type Passkey struct {
+	ID         string     `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
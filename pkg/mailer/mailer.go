@@ -0,0 +1,155 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional mail such as sign-in links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type SMTPMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer sends through an SMTP relay. It upgrades to TLS with
+// STARTTLS when the server offers it, and net/smtp refuses PLAIN auth
+// without TLS.
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: net.JoinHostPort(host, port), host: host, from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send does what smtp.SendMail does, but dials with ctx and puts the ctx
+// deadline on the connection, so a relay that stops answering cannot hold
+// the sending goroutine forever.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("error(SMTPMailer.Send): dial %s: %w", m.addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("error(SMTPMailer.Send): set deadline: %w", err)
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := m.send(conn, msg); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("error(SMTPMailer.Send): send to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) send(conn net.Conn, msg Message) error {
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("server does not support AUTH")
+		}
+		if err := client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.format(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m *SMTPMailer) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// LogMailer does not deliver anything: it appends messages to a file, or
+// writes them to the service log when no path is set. Meant for local
+// development and tests. Tokens in links are redacted in the service log,
+// which is often shipped elsewhere; only the file keeps usable links.
+type LogMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+var linkToken = regexp.MustCompile(`([?&]token=)[^&\s]+`)
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	text := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if m.path == "" {
+		log.Printf("mail %s", linkToken.ReplaceAllString(text, "${1}REDACTED"))
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error(LogMailer.Send): open %s: %w", m.path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text + "\n"); err != nil {
+		return fmt.Errorf("error(LogMailer.Send): write %s: %w", m.path, err)
+	}
+	return nil
+}
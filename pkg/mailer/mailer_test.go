@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSMTPMailerSendStopsAtDeadline(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accept and never greet, like a relay that hangs.
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	m := NewSMTPMailer(host, port, "", "", "from@example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = m.Send(ctx, Message{To: "to@example.com", Subject: "s", Body: "b"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() took %v after the deadline", elapsed)
+	}
+}
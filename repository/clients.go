@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OAuthClient struct {
+	ClientID               string    `db:"client_id"`
+	SecretHash             string    `db:"secret_hash"`
+	Name                   string    `db:"name"`
+	Scopes                 string    `db:"scopes"`
+	GrantTypes             string    `db:"grant_types"`
+	RedirectURIs           string    `db:"redirect_uris"`
+	Audiences              string    `db:"audiences"`
+	Public                 bool      `db:"public"`
+	AccessTokenTTLSeconds  int       `db:"access_token_ttl_seconds"`
+	RefreshTokenTTLSeconds int       `db:"refresh_token_ttl_seconds"`
+	CreatedAt              time.Time `db:"created_at"`
+}
+
+const oauthClientColumns = "client_id, secret_hash, name, scopes, grant_types, redirect_uris, audiences, public, access_token_ttl_seconds, refresh_token_ttl_seconds, created_at"
+
+func (r *Repository) CreateClient(ctx context.Context, client *OAuthClient) error {
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO oauth_clients (client_id, secret_hash, name, scopes, grant_types, redirect_uris, audiences, public, access_token_ttl_seconds, refresh_token_ttl_seconds, created_at) VALUES (:client_id, :secret_hash, :name, :scopes, :grant_types, :redirect_uris, :audiences, :public, :access_token_ttl_seconds, :refresh_token_ttl_seconds, NOW())",
+		client)
+	if err != nil {
+		return fmt.Errorf("error(CreateClient): insert client: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	var client OAuthClient
+	err := r.db.GetContext(ctx, &client, "SELECT "+oauthClientColumns+" FROM oauth_clients WHERE client_id = $1", clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetClient): query client: %w", err)
+	}
+	return &client, nil
+}
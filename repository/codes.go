@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type AuthorizationCode struct {
+	Selector      string `db:"selector"`
+	CodeHash      string `db:"code_hash"`
+	ClientID      string `db:"client_id"`
+	UserID        string `db:"user_id"`
+	RedirectURI   string `db:"redirect_uri"`
+	Scope         string `db:"scope"`
+	CodeChallenge string `db:"code_challenge"`
+	Nonce         string `db:"nonce"`
+	AMR           string `db:"amr"`
+	// FamilyID is the session issued for the code, set when it is redeemed.
+	FamilyID  string    `db:"family_id"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Used      bool      `db:"used"`
+}
+
+// authorizationCodeRetention keeps expired codes around for a while, so a
+// late replay of a redeemed code still finds the session to revoke.
+const authorizationCodeRetention = 24 * time.Hour
+
+const authorizationCodeColumns = "selector, code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, amr, family_id, created_at, expires_at, used"
+
+func (r *Repository) SaveAuthorizationCode(ctx context.Context, code *AuthorizationCode) error {
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO authorization_codes (selector, code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, amr, created_at, expires_at, used) VALUES (:selector, :code_hash, :client_id, :user_id, :redirect_uri, :scope, :code_challenge, :nonce, :amr, NOW(), :expires_at, false)",
+		code)
+	if err != nil {
+		return fmt.Errorf("error(SaveAuthorizationCode): insert code: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM authorization_codes WHERE expires_at < $1", time.Now().Add(-authorizationCodeRetention)); err != nil {
+		return fmt.Errorf("error(SaveAuthorizationCode): purge expired codes: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetAuthorizationCode(ctx context.Context, selector string) (*AuthorizationCode, error) {
+	var code AuthorizationCode
+	err := r.db.GetContext(ctx, &code, "SELECT "+authorizationCodeColumns+" FROM authorization_codes WHERE selector = $1", selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetAuthorizationCode): query code: %w", err)
+	}
+	return &code, nil
+}
+
+// MarkAuthorizationCodeUsed records the session issued for the code. It
+// reports false when another request redeemed the code first.
+func (r *Repository) MarkAuthorizationCodeUsed(ctx context.Context, selector, familyID string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE authorization_codes SET used = true, family_id = $2 WHERE selector = $1 AND used = false", selector, familyID)
+	if err != nil {
+		return false, fmt.Errorf("error(MarkAuthorizationCodeUsed): mark code used: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(MarkAuthorizationCodeUsed): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+type DeviceCode struct {
+	Selector        string       `db:"selector"`
+	CodeHash        string       `db:"code_hash"`
+	UserCode        string       `db:"user_code"`
+	ClientID        string       `db:"client_id"`
+	Scope           string       `db:"scope"`
+	UserID          string       `db:"user_id"`
+	AMR             string       `db:"amr"`
+	Status          string       `db:"status"`
+	IntervalSeconds int          `db:"interval_seconds"`
+	LastPolledAt    sql.NullTime `db:"last_polled_at"`
+	CreatedAt       time.Time    `db:"created_at"`
+	ExpiresAt       time.Time    `db:"expires_at"`
+	Used            bool         `db:"used"`
+}
+
+const deviceCodeColumns = "selector, code_hash, user_code, client_id, scope, user_id, amr, status, interval_seconds, last_polled_at, created_at, expires_at, used"
+
+func (r *Repository) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM device_codes WHERE expires_at < $1", time.Now()); err != nil {
+		return fmt.Errorf("error(SaveDeviceCode): purge expired codes: %w", err)
+	}
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO device_codes (selector, code_hash, user_code, client_id, scope, user_id, status, interval_seconds, created_at, expires_at, used) VALUES (:selector, :code_hash, :user_code, :client_id, :scope, '', 'pending', :interval_seconds, NOW(), :expires_at, false)",
+		code)
+	if err != nil {
+		return fmt.Errorf("error(SaveDeviceCode): insert code: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetDeviceCode(ctx context.Context, selector string) (*DeviceCode, error) {
+	var code DeviceCode
+	err := r.db.GetContext(ctx, &code, "SELECT "+deviceCodeColumns+" FROM device_codes WHERE selector = $1", selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetDeviceCode): query code: %w", err)
+	}
+	return &code, nil
+}
+
+func (r *Repository) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	var code DeviceCode
+	err := r.db.GetContext(ctx, &code, "SELECT "+deviceCodeColumns+" FROM device_codes WHERE user_code = $1 AND expires_at > $2",
+		userCode, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetDeviceCodeByUserCode): query code: %w", err)
+	}
+	return &code, nil
+}
+
+// DecideDeviceCode records the user's decision. It reports false when the
+// code was already decided, so a user_code cannot be approved twice.
+func (r *Repository) DecideDeviceCode(ctx context.Context, selector, userID, amr, status string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE device_codes SET user_id = $2, amr = $3, status = $4 WHERE selector = $1 AND status = 'pending'",
+		selector, userID, amr, status)
+	if err != nil {
+		return false, fmt.Errorf("error(DecideDeviceCode): update code: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(DecideDeviceCode): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+func (r *Repository) TouchDeviceCode(ctx context.Context, selector string, polledAt time.Time, intervalSeconds int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE device_codes SET last_polled_at = $2, interval_seconds = $3 WHERE selector = $1",
+		selector, polledAt, intervalSeconds)
+	if err != nil {
+		return fmt.Errorf("error(TouchDeviceCode): update code: %w", err)
+	}
+	return nil
+}
+
+// MarkDeviceCodeUsed reports false when another poll redeemed the approved
+// code first.
+func (r *Repository) MarkDeviceCodeUsed(ctx context.Context, selector string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE device_codes SET used = true WHERE selector = $1 AND status = 'approved' AND used = false", selector)
+	if err != nil {
+		return false, fmt.Errorf("error(MarkDeviceCodeUsed): mark code used: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(MarkDeviceCodeUsed): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// CountUserCodeFailure records a wrong user_code typed by key (a user or an
+// IP address) and returns how many there have been since windowStart.
+// Failures from before windowStart are forgotten.
+func (r *Repository) CountUserCodeFailure(ctx context.Context, key string, now, windowStart time.Time) (int, error) {
+	var failures int
+	err := r.db.GetContext(ctx, &failures, "INSERT INTO user_code_failures (throttle_key, failures, window_start) VALUES ($1, 1, $2) ON CONFLICT (throttle_key) DO UPDATE SET failures = CASE WHEN user_code_failures.window_start < $3 THEN 1 ELSE user_code_failures.failures + 1 END, window_start = CASE WHEN user_code_failures.window_start < $3 THEN $2 ELSE user_code_failures.window_start END RETURNING failures",
+		key, now, windowStart)
+	if err != nil {
+		return 0, fmt.Errorf("error(CountUserCodeFailure): upsert failures: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *Repository) GetUserCodeFailures(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	var failures int
+	err := r.db.GetContext(ctx, &failures, "SELECT failures FROM user_code_failures WHERE throttle_key = $1 AND window_start >= $2", key, windowStart)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error(GetUserCodeFailures): query failures: %w", err)
+	}
+	return failures, nil
+}
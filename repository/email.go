@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Purposes of single-use tokens sent by email.
+const (
+	EmailTokenLogin         = "login"
+	EmailTokenPasswordReset = "password_reset"
+	EmailTokenVerification  = "email_verification"
+)
+
+type EmailToken struct {
+	Selector  string    `db:"selector"`
+	TokenHash string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	Purpose   string    `db:"purpose"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Used      bool      `db:"used"`
+}
+
+const emailTokenColumns = "selector, token_hash, user_id, purpose, created_at, expires_at, used"
+
+// SaveEmailToken stores a new token and drops the user's earlier unused
+// tokens for the same purpose, so only the latest email works.
+func (r *Repository) SaveEmailToken(ctx context.Context, token *EmailToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error(SaveEmailToken): begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM email_tokens WHERE expires_at < $1 OR (user_id = $2 AND purpose = $3)",
+		time.Now(), token.UserID, token.Purpose); err != nil {
+		return fmt.Errorf("error(SaveEmailToken): purge tokens: %w", err)
+	}
+	_, err = tx.NamedExecContext(ctx, "INSERT INTO email_tokens (selector, token_hash, user_id, purpose, created_at, expires_at, used) VALUES (:selector, :token_hash, :user_id, :purpose, NOW(), :expires_at, false)",
+		token)
+	if err != nil {
+		return fmt.Errorf("error(SaveEmailToken): insert token: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error(SaveEmailToken): commit: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetEmailToken(ctx context.Context, selector string) (*EmailToken, error) {
+	var token EmailToken
+	err := r.db.GetContext(ctx, &token, "SELECT "+emailTokenColumns+" FROM email_tokens WHERE selector = $1", selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetEmailToken): query token: %w", err)
+	}
+	return &token, nil
+}
+
+// UseEmailToken reports false when the token was already used.
+func (r *Repository) UseEmailToken(ctx context.Context, selector string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE email_tokens SET used = true WHERE selector = $1 AND used = false", selector)
+	if err != nil {
+		return false, fmt.Errorf("error(UseEmailToken): update token: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(UseEmailToken): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// ResetPassword replaces the password hash and, in the same transaction,
+// marks the email verified and drops the user's pending MFA challenges and
+// unused emailed tokens, which were issued under the old password. Passkeys
+// go too: whoever took over the account may have enrolled one.
+func (r *Repository) ResetPassword(ctx context.Context, userID, passwordHash string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error(ResetPassword): begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = $2, email_verified = true, updated_at = NOW() WHERE id = $1", userID, passwordHash); err != nil {
+		return fmt.Errorf("error(ResetPassword): update user: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_challenges WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("error(ResetPassword): delete mfa challenges: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM email_tokens WHERE user_id = $1 AND used = false", userID); err != nil {
+		return fmt.Errorf("error(ResetPassword): delete email tokens: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("error(ResetPassword): delete passkeys: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webauthn_challenges WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("error(ResetPassword): delete passkey challenges: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error(ResetPassword): commit: %w", err)
+	}
+	return nil
+}
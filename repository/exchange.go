@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type TokenExchange struct {
+	ID            int       `db:"id"`
+	ClientID      string    `db:"client_id"`
+	SubjectID     string    `db:"subject_id"`
+	ActorID       string    `db:"actor_id"`
+	Impersonation bool      `db:"impersonation"`
+	Scope         string    `db:"scope"`
+	Audience      string    `db:"audience"`
+	TokenID       string    `db:"token_id"`
+	Granted       bool      `db:"granted"`
+	Reason        string    `db:"reason"`
+	IPAddress     string    `db:"ip_address"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// SaveTokenExchange appends to the token exchange audit log. Rows are never
+// updated or purged by the service.
+func (r *Repository) SaveTokenExchange(ctx context.Context, exchange *TokenExchange) error {
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO token_exchanges (client_id, subject_id, actor_id, impersonation, scope, audience, token_id, granted, reason, ip_address, created_at) VALUES (:client_id, :subject_id, :actor_id, :impersonation, :scope, :audience, :token_id, :granted, :reason, :ip_address, NOW())",
+		exchange)
+	if err != nil {
+		return fmt.Errorf("error(SaveTokenExchange): insert audit record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UseRecoveryCode burns the code and reports whether it was valid and unused.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE mfa_recovery_codes SET used = true WHERE user_id = $1 AND code_hash = $2 AND used = false",
+		userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("error(UseRecoveryCode): update code: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(UseRecoveryCode): rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+type MFAChallenge struct {
+	Selector  string    `db:"selector"`
+	TokenHash string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	AMR       string    `db:"amr"`
+	Attempts  int       `db:"attempts"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Used      bool      `db:"used"`
+}
+
+const mfaChallengeColumns = "selector, token_hash, user_id, amr, attempts, created_at, expires_at, used"
+
+func (r *Repository) SaveMFAChallenge(ctx context.Context, challenge *MFAChallenge) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM mfa_challenges WHERE expires_at < $1", time.Now()); err != nil {
+		return fmt.Errorf("error(SaveMFAChallenge): purge expired challenges: %w", err)
+	}
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO mfa_challenges (selector, token_hash, user_id, amr, attempts, created_at, expires_at, used) VALUES (:selector, :token_hash, :user_id, :amr, 0, NOW(), :expires_at, false)",
+		challenge)
+	if err != nil {
+		return fmt.Errorf("error(SaveMFAChallenge): insert challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetMFAChallenge(ctx context.Context, selector string) (*MFAChallenge, error) {
+	var challenge MFAChallenge
+	err := r.db.GetContext(ctx, &challenge, "SELECT "+mfaChallengeColumns+" FROM mfa_challenges WHERE selector = $1", selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetMFAChallenge): query challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// CountMFAChallengeAttempt records a wrong code and reports false once the
+// challenge has run out of attempts.
+func (r *Repository) CountMFAChallengeAttempt(ctx context.Context, selector string, maxAttempts int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE selector = $1 AND attempts < $2", selector, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("error(CountMFAChallengeAttempt): update challenge: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(CountMFAChallengeAttempt): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// MarkMFAChallengeUsed reports false when another request completed the
+// challenge first.
+func (r *Repository) MarkMFAChallengeUsed(ctx context.Context, selector string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE mfa_challenges SET used = true WHERE selector = $1 AND used = false", selector)
+	if err != nil {
+		return false, fmt.Errorf("error(MarkMFAChallengeUsed): mark challenge used: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(MarkMFAChallengeUsed): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
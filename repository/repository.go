@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"time"
@@ -16,28 +18,77 @@ func NewRepository(db *sqlx.DB) *Repository {
 }
 
 type RefreshToken struct {
-	ID        int       `db:"id"`
-	UserID    string    `db:"user_id"`
-	TokenHash string    `db:"token_hash"`
-	UserAgent string    `db:"user_agent"`
-	IPAddress string    `db:"ip_address"`
-	CreatedAt time.Time `db:"created_at"`
-	ExpiresAt time.Time `db:"expires_at"`
-	Used      bool      `db:"used"`
-	TokenID   string    `db:"token_id"`
+	ID              int       `db:"id"`
+	UserID          string    `db:"user_id"`
+	Selector        string    `db:"selector"`
+	TokenHash       string    `db:"token_hash"`
+	UserAgent       string    `db:"user_agent"`
+	IPAddress       string    `db:"ip_address"`
+	CreatedAt       time.Time `db:"created_at"`
+	LastUsedAt      time.Time `db:"last_used_at"`
+	ExpiresAt       time.Time `db:"expires_at"`
+	Used            bool      `db:"used"`
+	TokenID         string    `db:"token_id"`
+	FamilyID        string    `db:"family_id"`
+	ClientID        string    `db:"client_id"`
+	Scope           string    `db:"scope"`
+	AMR             string    `db:"amr"`
+	AccessExpiresAt time.Time `db:"access_expires_at"`
 }
 
-func (r *Repository) SaveRefreshToken(ctx context.Context, userID, tokenHash, userAgent, ip string, expiresAt time.Time, tokenID string) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, token_hash, user_agent, ip_address, created_at, expires_at, used, token_id) VALUES ($1, $2, $3, $4, NOW(), $5, false, $6)",
-		userID, tokenHash, userAgent, ip, expiresAt, tokenID)
+const refreshTokenColumns = "id, user_id, selector, token_hash, user_agent, ip_address, created_at, last_used_at, expires_at, used, token_id, family_id, client_id, scope, amr, access_expires_at"
+
+var ErrTokenAlreadyUsed = errors.New("refresh token already used")
+
+// SaveRefreshToken stores a token of the rotation chain FamilyID started at
+// CreatedAt; rotated tokens keep both so the session survives refreshes.
+func (r *Repository) SaveRefreshToken(ctx context.Context, token *RefreshToken) error {
+	if err := insertRefreshToken(ctx, r.db, token); err != nil {
+		return fmt.Errorf("error(SaveRefreshToken): %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken marks oldID used and stores its successor in one
+// transaction. The conditional update lets exactly one concurrent caller win;
+// the others get ErrTokenAlreadyUsed.
+func (r *Repository) RotateRefreshToken(ctx context.Context, oldID int, token *RefreshToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error(SaveRefreshToken): save refresh token: %w", err)
+		return fmt.Errorf("error(RotateRefreshToken): begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used = true WHERE id = $1 AND used = false", oldID)
+	if err != nil {
+		return fmt.Errorf("error(RotateRefreshToken): mark token as used: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error(RotateRefreshToken): rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("error(RotateRefreshToken): %w", ErrTokenAlreadyUsed)
+	}
+	if err := insertRefreshToken(ctx, tx, token); err != nil {
+		return fmt.Errorf("error(RotateRefreshToken): %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error(RotateRefreshToken): commit: %w", err)
+	}
+	return nil
+}
+
+func insertRefreshToken(ctx context.Context, db sqlx.ExtContext, token *RefreshToken) error {
+	_, err := sqlx.NamedExecContext(ctx, db, "INSERT INTO refresh_tokens (user_id, selector, token_hash, user_agent, ip_address, created_at, last_used_at, expires_at, used, token_id, family_id, client_id, scope, amr, access_expires_at) VALUES (:user_id, :selector, :token_hash, :user_agent, :ip_address, :created_at, NOW(), :expires_at, false, :token_id, :family_id, :client_id, :scope, :amr, :access_expires_at)",
+		token)
+	if err != nil {
+		return fmt.Errorf("error(insertRefreshToken): save refresh token: %w", err)
 	}
 	return nil
 }
 
 func (r *Repository) GetRefreshTokensByUser(ctx context.Context, userID string) ([]RefreshToken, error) {
-	rows, err := r.db.QueryxContext(ctx, "SELECT id, user_id, token_hash, user_agent, ip_address, created_at, expires_at, used, token_id FROM refresh_tokens WHERE user_id = $1",
+	rows, err := r.db.QueryxContext(ctx, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE user_id = $1",
 		userID)
 	if err != nil {
 		return nil, fmt.Errorf("error(GetRefreshTokensByUser): query refresh tokens: %w", err)
@@ -54,12 +105,54 @@ func (r *Repository) GetRefreshTokensByUser(ctx context.Context, userID string)
 	return tokens, nil
 }
 
-func (r *Repository) MarkTokenUsed(ctx context.Context, tokenHash string) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET used = true WHERE token_hash = $1", tokenHash)
+func (r *Repository) GetRefreshTokenBySelector(ctx context.Context, selector string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.GetContext(ctx, &token, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE selector = $1", selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("error(MarkTokenUsed): mark token as used: %w", err)
+		return nil, fmt.Errorf("error(GetRefreshTokenBySelector): query refresh token: %w", err)
 	}
-	return nil
+	return &token, nil
+}
+
+func (r *Repository) GetActiveSessions(ctx context.Context, userID string) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+	err := r.db.SelectContext(ctx, &tokens, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE user_id = $1 AND used = false AND expires_at > $2 ORDER BY last_used_at DESC",
+		userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error(GetActiveSessions): query sessions: %w", err)
+	}
+	return tokens, nil
+}
+
+func (r *Repository) GetRefreshTokenByTokenID(ctx context.Context, userID, tokenID string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.GetContext(ctx, &token, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE user_id = $1 AND token_id = $2",
+		userID, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetRefreshTokenByTokenID): query refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetSessionByFamilyID returns the live refresh token of the user's session
+// familyID, or nil when the session has ended.
+func (r *Repository) GetSessionByFamilyID(ctx context.Context, userID, familyID string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.GetContext(ctx, &token, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE user_id = $1 AND family_id = $2 AND used = false AND expires_at > $3",
+		userID, familyID, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetSessionByFamilyID): query session: %w", err)
+	}
+	return &token, nil
 }
 
 func (r *Repository) DeleteTokensByUserID(ctx context.Context, userID string) error {
@@ -69,3 +162,12 @@ func (r *Repository) DeleteTokensByUserID(ctx context.Context, userID string) er
 	}
 	return nil
 }
+
+func (r *Repository) DeleteTokenFamily(ctx context.Context, familyID string) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+	err := r.db.SelectContext(ctx, &tokens, "DELETE FROM refresh_tokens WHERE family_id = $1 RETURNING "+refreshTokenColumns, familyID)
+	if err != nil {
+		return nil, fmt.Errorf("error(DeleteTokenFamily): delete token family: %w", err)
+	}
+	return tokens, nil
+}
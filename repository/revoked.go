@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (r *Repository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING", jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("error(RevokeAccessToken): insert revoked token: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < $1", time.Now()); err != nil {
+		return fmt.Errorf("error(RevokeAccessToken): purge expired entries: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := r.db.GetContext(ctx, &revoked, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at >= $2)", jti, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("error(IsAccessTokenRevoked): query revoked token: %w", err)
+	}
+	return revoked, nil
+}
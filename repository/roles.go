@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+type Role struct {
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Permissions pq.StringArray `db:"permissions"`
+}
+
+// SaveRole creates the role or replaces its description and permission set.
+func (r *Repository) SaveRole(ctx context.Context, role *Role) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error(SaveRole): begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "INSERT INTO roles (name, description) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description",
+		role.Name, role.Description)
+	if err != nil {
+		return fmt.Errorf("error(SaveRole): upsert role: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = $1", role.Name); err != nil {
+		return fmt.Errorf("error(SaveRole): clear permissions: %w", err)
+	}
+	for _, permission := range role.Permissions {
+		_, err := tx.ExecContext(ctx, "INSERT INTO role_permissions (role, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			role.Name, permission)
+		if err != nil {
+			return fmt.Errorf("error(SaveRole): insert permission: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error(SaveRole): commit: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) ListRoles(ctx context.Context) ([]Role, error) {
+	roles := []Role{}
+	err := r.db.SelectContext(ctx, &roles, "SELECT r.name, r.description, COALESCE(array_agg(p.permission ORDER BY p.permission) FILTER (WHERE p.permission IS NOT NULL), '{}') AS permissions FROM roles r LEFT JOIN role_permissions p ON p.role = r.name GROUP BY r.name, r.description ORDER BY r.name")
+	if err != nil {
+		return nil, fmt.Errorf("error(ListRoles): query roles: %w", err)
+	}
+	return roles, nil
+}
+
+// AssignRole reports false when the role does not exist.
+func (r *Repository) AssignRole(ctx context.Context, userID, role string) (bool, error) {
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", role); err != nil {
+		return false, fmt.Errorf("error(AssignRole): query role: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+	_, err := r.db.ExecContext(ctx, "INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, role)
+	if err != nil {
+		return false, fmt.Errorf("error(AssignRole): insert user role: %w", err)
+	}
+	return true, nil
+}
+
+// UnassignRole reports false when the user did not have the role.
+func (r *Repository) UnassignRole(ctx context.Context, userID, role string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role = $2", userID, role)
+	if err != nil {
+		return false, fmt.Errorf("error(UnassignRole): delete user role: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(UnassignRole): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// GetUserAuthorization returns the user's roles and the union of their
+// permissions, both sorted.
+func (r *Repository) GetUserAuthorization(ctx context.Context, userID string) ([]string, []string, error) {
+	roles, permissions := []string{}, []string{}
+	if err := r.db.SelectContext(ctx, &roles, "SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role", userID); err != nil {
+		return nil, nil, fmt.Errorf("error(GetUserAuthorization): query roles: %w", err)
+	}
+	err := r.db.SelectContext(ctx, &permissions, "SELECT DISTINCT p.permission FROM user_roles u JOIN role_permissions p ON p.role = u.role WHERE u.user_id = $1 ORDER BY p.permission",
+		userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error(GetUserAuthorization): query permissions: %w", err)
+	}
+	return roles, permissions, nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+type User struct {
+	ID            string `db:"id"`
+	Email         string `db:"email"`
+	PasswordHash  string `db:"password_hash"`
+	EmailVerified bool   `db:"email_verified"`
+	TOTPSecret    string `db:"totp_secret"`
+	TOTPEnabled   bool   `db:"totp_enabled"`
+	TOTPLastStep  int64  `db:"totp_last_step"`
+	// TOTPFailedAttempts counts second-factor attempts since the last
+	// successful one; TOTPLockedUntil is set once there were too many.
+	TOTPFailedAttempts int          `db:"totp_failed_attempts"`
+	TOTPLockedUntil    sql.NullTime `db:"totp_locked_until"`
+	CreatedAt          time.Time    `db:"created_at"`
+	UpdatedAt          time.Time    `db:"updated_at"`
+}
+
+const userColumns = "id, email, password_hash, email_verified, totp_secret, totp_enabled, totp_last_step, totp_failed_attempts, totp_locked_until, created_at, updated_at"
+
+var ErrUserExists = errors.New("user already exists")
+
+func (r *Repository) CreateUser(ctx context.Context, user *User) error {
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO users (id, email, password_hash, created_at, updated_at) VALUES (:id, :email, :password_hash, NOW(), NOW())",
+		user)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("error(CreateUser): %w", ErrUserExists)
+	}
+	if err != nil {
+		return fmt.Errorf("error(CreateUser): insert user: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	err := r.db.GetContext(ctx, &user, "SELECT "+userColumns+" FROM users WHERE email = $1", email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetUserByEmail): query user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) GetUserByID(ctx context.Context, id string) (*User, error) {
+	var user User
+	err := r.db.GetContext(ctx, &user, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetUserByID): query user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1", userID, passwordHash)
+	if err != nil {
+		return fmt.Errorf("error(UpdatePasswordHash): update user: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) MarkEmailVerified(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET email_verified = true, updated_at = NOW() WHERE id = $1 AND email_verified = false", userID)
+	if err != nil {
+		return fmt.Errorf("error(MarkEmailVerified): update user: %w", err)
+	}
+	return nil
+}
+
+// SetTOTPSecret stores a secret awaiting confirmation. It is refused once
+// TOTP is enabled, so enrollment cannot silently replace a working factor.
+func (r *Repository) SetTOTPSecret(ctx context.Context, userID, secret string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET totp_secret = $2, totp_last_step = 0, updated_at = NOW() WHERE id = $1 AND totp_enabled = false",
+		userID, secret)
+	if err != nil {
+		return false, fmt.Errorf("error(SetTOTPSecret): update user: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(SetTOTPSecret): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// EnableTOTP turns TOTP on and replaces the recovery codes in one
+// transaction. It reports false when TOTP was already enabled, so two
+// concurrent confirmations cannot both hand out recovery codes.
+func (r *Repository) EnableTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error(EnableTOTP): begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled = true, totp_last_step = $2, totp_failed_attempts = 0, totp_locked_until = NULL, updated_at = NOW() WHERE id = $1 AND totp_enabled = false",
+		userID, step)
+	if err != nil {
+		return false, fmt.Errorf("error(EnableTOTP): update user: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(EnableTOTP): rows affected: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return false, fmt.Errorf("error(EnableTOTP): delete recovery codes: %w", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return false, fmt.Errorf("error(EnableTOTP): insert recovery code: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error(EnableTOTP): commit: %w", err)
+	}
+	return true, nil
+}
+
+func (r *Repository) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error(DisableTOTP): begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "UPDATE users SET totp_secret = '', totp_enabled = false, totp_last_step = 0, totp_failed_attempts = 0, totp_locked_until = NULL, updated_at = NOW() WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("error(DisableTOTP): update user: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("error(DisableTOTP): delete recovery codes: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error(DisableTOTP): commit: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It reports false
+// when that step or a later one was already used, which stops a code seen
+// over the user's shoulder from being replayed.
+func (r *Repository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2", userID, step)
+	if err != nil {
+		return false, fmt.Errorf("error(UseTOTPStep): update user: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(UseTOTPStep): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// CountTOTPAttempt records a second-factor attempt before the code is
+// checked, so parallel guesses cannot slip past the limit. The attempt that
+// reaches maxAttempts locks the user until lockedUntil; an expired lock
+// starts the count over. It reports false while the user is locked.
+func (r *Repository) CountTOTPAttempt(ctx context.Context, userID string, maxAttempts int, now, lockedUntil time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET totp_failed_attempts = CASE WHEN totp_locked_until IS NULL THEN totp_failed_attempts + 1 ELSE 1 END, totp_locked_until = CASE WHEN totp_locked_until IS NULL AND totp_failed_attempts + 1 >= $2 THEN $4 END WHERE id = $1 AND (totp_locked_until IS NULL OR totp_locked_until <= $3)",
+		userID, maxAttempts, now, lockedUntil)
+	if err != nil {
+		return false, fmt.Errorf("error(CountTOTPAttempt): update user: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(CountTOTPAttempt): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+func (r *Repository) ResetTOTPFailures(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET totp_failed_attempts = 0, totp_locked_until = NULL WHERE id = $1 AND totp_failed_attempts > 0", userID)
+	if err != nil {
+		return fmt.Errorf("error(ResetTOTPFailures): update user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+var ErrCredentialExists = errors.New("credential already registered")
+
+// WebAuthnCredential is a registered passkey. BackupEligible is fixed when
+// the credential is created; an assertion that reports otherwise is refused.
+type WebAuthnCredential struct {
+	ID             string       `db:"id"`
+	UserID         string       `db:"user_id"`
+	PublicKey      []byte       `db:"public_key"`
+	SignCount      int64        `db:"sign_count"`
+	BackupEligible bool         `db:"backup_eligible"`
+	CreatedAt      time.Time    `db:"created_at"`
+	LastUsedAt     sql.NullTime `db:"last_used_at"`
+}
+
+const webAuthnCredentialColumns = "id, user_id, public_key, sign_count, backup_eligible, created_at, last_used_at"
+
+func (r *Repository) CreateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error {
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO webauthn_credentials (id, user_id, public_key, sign_count, backup_eligible, created_at) VALUES (:id, :user_id, :public_key, :sign_count, :backup_eligible, NOW())",
+		credential)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("error(CreateWebAuthnCredential): %w", ErrCredentialExists)
+	}
+	if err != nil {
+		return fmt.Errorf("error(CreateWebAuthnCredential): insert credential: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetWebAuthnCredential(ctx context.Context, id string) (*WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	err := r.db.GetContext(ctx, &credential, "SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(GetWebAuthnCredential): query credential: %w", err)
+	}
+	return &credential, nil
+}
+
+func (r *Repository) ListWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+	err := r.db.SelectContext(ctx, &credentials, "SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at",
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListWebAuthnCredentials): query credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// DeleteWebAuthnCredential removes a credential of the user. It reports
+// false when the user has no credential with that id.
+func (r *Repository) DeleteWebAuthnCredential(ctx context.Context, userID, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("error(DeleteWebAuthnCredential): delete credential: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(DeleteWebAuthnCredential): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// UpdateWebAuthnSignCount stores the counter of a successful assertion. It
+// reports false when the counter did not grow, unless the authenticator
+// does not keep one and both values are zero; a concurrent login with a
+// cloned authenticator loses this race.
+func (r *Repository) UpdateWebAuthnSignCount(ctx context.Context, id string, signCount int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE webauthn_credentials SET sign_count = $2, last_used_at = NOW() WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))",
+		id, signCount)
+	if err != nil {
+		return false, fmt.Errorf("error(UpdateWebAuthnSignCount): update credential: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error(UpdateWebAuthnSignCount): rows affected: %w", err)
+	}
+	return affected == 1, nil
+}
+
+type WebAuthnChallenge struct {
+	ChallengeHash string    `db:"challenge_hash"`
+	Ceremony      string    `db:"ceremony"`
+	UserID        string    `db:"user_id"`
+	CreatedAt     time.Time `db:"created_at"`
+	ExpiresAt     time.Time `db:"expires_at"`
+	Used          bool      `db:"used"`
+}
+
+func (r *Repository) SaveWebAuthnChallenge(ctx context.Context, challenge *WebAuthnChallenge) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM webauthn_challenges WHERE expires_at < $1", time.Now()); err != nil {
+		return fmt.Errorf("error(SaveWebAuthnChallenge): purge expired challenges: %w", err)
+	}
+	_, err := r.db.NamedExecContext(ctx, "INSERT INTO webauthn_challenges (challenge_hash, ceremony, user_id, created_at, expires_at, used) VALUES (:challenge_hash, :ceremony, :user_id, NOW(), :expires_at, false)",
+		challenge)
+	if err != nil {
+		return fmt.Errorf("error(SaveWebAuthnChallenge): insert challenge: %w", err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnChallenge marks an unexpired challenge of the ceremony used
+// and returns it, or nil when there is none to use.
+func (r *Repository) ConsumeWebAuthnChallenge(ctx context.Context, challengeHash, ceremony string) (*WebAuthnChallenge, error) {
+	var challenge WebAuthnChallenge
+	err := r.db.GetContext(ctx, &challenge, "UPDATE webauthn_challenges SET used = true WHERE challenge_hash = $1 AND ceremony = $2 AND used = false AND expires_at > $3 RETURNING challenge_hash, ceremony, user_id, created_at, expires_at, used",
+		challengeHash, ceremony, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(ConsumeWebAuthnChallenge): update challenge: %w", err)
+	}
+	return &challenge, nil
+}
@@ -1,11 +1,206 @@
-CREATE TABLE refresh_tokens (
+CREATE TABLE IF NOT EXISTS refresh_tokens (
     id SERIAL PRIMARY KEY,
     user_id TEXT NOT NULL,
+    selector TEXT NOT NULL UNIQUE,
     token_hash TEXT NOT NULL,
     user_agent TEXT NOT NULL,
     ip_address TEXT NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
     expires_at TIMESTAMP NOT NULL,
     used BOOLEAN NOT NULL DEFAULT FALSE,
-    token_id TEXT NOT NULL
-);
\ No newline at end of file
+    token_id TEXT NOT NULL,
+    family_id TEXT NOT NULL,
+    client_id TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT '',
+    amr TEXT NOT NULL DEFAULT '',
+    access_expires_at TIMESTAMP NOT NULL
+);
+
+-- Refresh tokens stored before the selector was added cannot be looked up
+-- any more: those sessions end and their users have to sign in again.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS selector TEXT;
+DELETE FROM refresh_tokens WHERE selector IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN selector SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_tokens_selector_key ON refresh_tokens (selector);
+
+-- Columns added after the first release; no-ops on a fresh database.
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS family_id TEXT,
+    ADD COLUMN IF NOT EXISTS client_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS amr TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS access_expires_at TIMESTAMP;
+UPDATE refresh_tokens SET family_id = token_id WHERE family_id IS NULL;
+UPDATE refresh_tokens SET access_expires_at = expires_at WHERE access_expires_at IS NULL;
+ALTER TABLE refresh_tokens
+    ALTER COLUMN family_id SET NOT NULL,
+    ALTER COLUMN access_expires_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    client_id TEXT PRIMARY KEY,
+    secret_hash TEXT NOT NULL,
+    name TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    grant_types TEXT NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL DEFAULT '',
+    audiences TEXT NOT NULL DEFAULT '',
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    access_token_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+    refresh_token_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS authorization_codes (
+    selector TEXT PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    amr TEXT NOT NULL DEFAULT '',
+    family_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS device_codes (
+    selector TEXT PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    user_code TEXT NOT NULL UNIQUE,
+    client_id TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    amr TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    interval_seconds INTEGER NOT NULL,
+    last_polled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS user_code_failures (
+    throttle_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    window_start TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS token_exchanges (
+    id SERIAL PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    actor_id TEXT NOT NULL,
+    impersonation BOOLEAN NOT NULL DEFAULT FALSE,
+    scope TEXT NOT NULL DEFAULT '',
+    audience TEXT NOT NULL DEFAULT '',
+    token_id TEXT NOT NULL DEFAULT '',
+    granted BOOLEAN NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS token_exchanges_subject_id_idx ON token_exchanges (subject_id);
+CREATE INDEX IF NOT EXISTS token_exchanges_actor_id_idx ON token_exchanges (actor_id);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id TEXT NOT NULL,
+    role TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret TEXT NOT NULL DEFAULT '',
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    totp_failed_attempts INTEGER NOT NULL DEFAULT 0,
+    totp_locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user_id_idx ON mfa_recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    selector TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    amr TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id_idx ON webauthn_credentials (user_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    challenge_hash TEXT PRIMARY KEY,
+    ceremony TEXT NOT NULL,
+    user_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS email_tokens (
+    selector TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    purpose TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS email_tokens_user_id_purpose_idx ON email_tokens (user_id, purpose);
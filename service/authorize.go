@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/repository"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	authorizationCodeTTL = time.Minute
+
+	EventAuthorizationCodeReuse = "authorization_code_reuse"
+)
+
+var (
+	ErrInvalidRedirectURI      = errors.New("invalid redirect uri")
+	ErrInvalidRequest          = errors.New("invalid request")
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+)
+
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// ValidateRedirect checks the client and redirect_uri. Until they are known to
+// be good, errors must be shown to the user instead of being redirected.
+func (s *Service) ValidateRedirect(ctx context.Context, clientID, redirectURI string) error {
+	if _, err := s.redirectClient(ctx, clientID, redirectURI); err != nil {
+		return fmt.Errorf("error(ValidateRedirect): %w", err)
+	}
+	return nil
+}
+
+func (s *Service) redirectClient(ctx context.Context, clientID, redirectURI string) (*repository.OAuthClient, error) {
+	client, err := s.repository.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("error(redirectClient): %w", err)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("error(redirectClient): %w", ErrInvalidClient)
+	}
+	if !slices.Contains(strings.Fields(client.RedirectURIs), redirectURI) {
+		return nil, fmt.Errorf("error(redirectClient): %w", ErrInvalidRedirectURI)
+	}
+	return client, nil
+}
+
+// AuthorizePrompt is what the login and consent page shows about a request.
+type AuthorizePrompt struct {
+	ClientName string
+	Scope      string
+}
+
+// CheckAuthorizeRequest validates a request before the user is asked to
+// sign in and approve it.
+func (s *Service) CheckAuthorizeRequest(ctx context.Context, req AuthorizeRequest) (*AuthorizePrompt, error) {
+	client, scope, err := s.checkAuthorizeRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error(CheckAuthorizeRequest): %w", err)
+	}
+	return &AuthorizePrompt{ClientName: client.Name, Scope: scope}, nil
+}
+
+// Authorize issues a single-use authorization code for the user owning
+// accessToken. PKCE with S256 is mandatory for every client.
+func (s *Service) Authorize(ctx context.Context, accessToken string, req AuthorizeRequest) (string, error) {
+	client, scope, err := s.checkAuthorizeRequest(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("error(Authorize): %w", err)
+	}
+	user, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return "", fmt.Errorf("error(Authorize): %w", err)
+	}
+	code, err := s.issueAuthorizationCode(ctx, client, scope, user.UserID, user.AMR, req)
+	if err != nil {
+		return "", fmt.Errorf("error(Authorize): %w", err)
+	}
+	return code, nil
+}
+
+// AuthorizeWithPassword issues a code for a user who signed in on the
+// login and consent page. Users with TOTP must send a code or a recovery
+// code with the password; without one ErrMFARequired is returned so the
+// page can ask for it. Wrong codes count towards the same per-user lockout
+// as /login/mfa.
+func (s *Service) AuthorizeWithPassword(ctx context.Context, email, password, code, recoveryCode string, req AuthorizeRequest) (string, error) {
+	client, scope, err := s.checkAuthorizeRequest(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("error(AuthorizeWithPassword): %w", err)
+	}
+	user, err := s.authenticatePassword(ctx, email, password)
+	if err != nil {
+		return "", fmt.Errorf("error(AuthorizeWithPassword): %w", err)
+	}
+	amr := []string{AMRPassword}
+	if user.TOTPEnabled {
+		if code == "" && recoveryCode == "" {
+			return "", fmt.Errorf("error(AuthorizeWithPassword): %w", ErrMFARequired)
+		}
+		if err := s.checkSecondFactor(ctx, user, code, recoveryCode); err != nil {
+			return "", fmt.Errorf("error(AuthorizeWithPassword): %w", err)
+		}
+		amr = append(amr, AMROTP, AMRMFA)
+	}
+	authCode, err := s.issueAuthorizationCode(ctx, client, scope, user.ID, amr, req)
+	if err != nil {
+		return "", fmt.Errorf("error(AuthorizeWithPassword): %w", err)
+	}
+	return authCode, nil
+}
+
+func (s *Service) checkAuthorizeRequest(ctx context.Context, req AuthorizeRequest) (*repository.OAuthClient, string, error) {
+	client, err := s.redirectClient(ctx, req.ClientID, req.RedirectURI)
+	if err != nil {
+		return nil, "", fmt.Errorf("error(checkAuthorizeRequest): %w", err)
+	}
+	if req.ResponseType != "code" {
+		return nil, "", fmt.Errorf("error(checkAuthorizeRequest): %w", ErrUnsupportedResponseType)
+	}
+	if req.CodeChallengeMethod != "S256" || req.CodeChallenge == "" {
+		return nil, "", fmt.Errorf("error(checkAuthorizeRequest): code_challenge with S256 is required: %w", ErrInvalidRequest)
+	}
+	if !clientAllowsGrant(client, GrantAuthorizationCode) {
+		return nil, "", fmt.Errorf("error(checkAuthorizeRequest): %w", ErrUnauthorizedClient)
+	}
+	scope, err := resolveScope(client, req.Scope)
+	if err != nil {
+		return nil, "", fmt.Errorf("error(checkAuthorizeRequest): %w", err)
+	}
+	return client, scope, nil
+}
+
+func (s *Service) issueAuthorizationCode(ctx context.Context, client *repository.OAuthClient, scope, userID string, amr []string, req AuthorizeRequest) (string, error) {
+	code, selector, codeHash, err := newSplitToken()
+	if err != nil {
+		return "", fmt.Errorf("error(issueAuthorizationCode): %w", err)
+	}
+	if err := s.repository.SaveAuthorizationCode(ctx, &repository.AuthorizationCode{
+		Selector:      selector,
+		CodeHash:      codeHash,
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AMR:           strings.Join(amr, " "),
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	}); err != nil {
+		return "", fmt.Errorf("error(issueAuthorizationCode): %w", err)
+	}
+	return code, nil
+}
+
+// ExchangeAuthorizationCode redeems a code for a token pair. clientID must
+// already be authenticated (confidential) or identified (public) by the caller.
+// A code presented twice was probably intercepted, so the session issued for
+// it the first time is revoked as RFC 6749 section 4.1.2 advises.
+func (s *Service) ExchangeAuthorizationCode(ctx context.Context, code, clientID, redirectURI, codeVerifier string, req TokenRequest) (*TokenPair, error) {
+	selector, verifier, ok := parseSplitToken(code)
+	if !ok {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): malformed code: %w", ErrInvalidGrant)
+	}
+	authCode, err := s.repository.GetAuthorizationCode(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): %w", err)
+	}
+	if authCode == nil || !verifierMatches(verifier, authCode.CodeHash) {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): code not found: %w", ErrInvalidGrant)
+	}
+	if authCode.Used {
+		return nil, s.authorizationCodeReused(ctx, authCode, req.IP)
+	}
+	if time.Now().After(authCode.ExpiresAt) {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): code expired: %w", ErrInvalidGrant)
+	}
+	if authCode.ClientID != clientID || authCode.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): client or redirect_uri mismatch: %w", ErrInvalidGrant)
+	}
+	if !pkceMatches(codeVerifier, authCode.CodeChallenge) {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): code_verifier mismatch: %w", ErrInvalidGrant)
+	}
+	familyID := uuid.New().String()
+	redeemed, err := s.repository.MarkAuthorizationCodeUsed(ctx, selector, familyID)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeAuthorizationCode): %w", err)
+	}
+	if !redeemed {
+		// Another request won the race; its session is revoked as well.
+		if authCode, err = s.repository.GetAuthorizationCode(ctx, selector); err != nil || authCode == nil {
+			return nil, fmt.Errorf("error(ExchangeAuthorizationCode): code already used: %w", ErrInvalidGrant)
+		}
+		return nil, s.authorizationCodeReused(ctx, authCode, req.IP)
+	}
+	req.FamilyID = familyID
+	req.UserID = authCode.UserID
+	req.ClientID = authCode.ClientID
+	req.Scope = authCode.Scope
+	req.Nonce = authCode.Nonce
+	req.AMR = strings.Fields(authCode.AMR)
+	return s.IssueTokens(ctx, req)
+}
+
+// authorizationCodeReused revokes the session issued for a code that is
+// presented again and returns the error for the second attempt.
+func (s *Service) authorizationCodeReused(ctx context.Context, authCode *repository.AuthorizationCode, ip string) error {
+	if authCode.FamilyID != "" {
+		if err := s.revokeFamily(ctx, authCode.FamilyID); err != nil {
+			return fmt.Errorf("error(ExchangeAuthorizationCode): %w", err)
+		}
+		s.emitSecurityEvent(EventAuthorizationCodeReuse, authCode.UserID, ip)
+	}
+	return fmt.Errorf("error(ExchangeAuthorizationCode): code already used: %w", ErrInvalidGrant)
+}
+
+// pkceMatches implements the RFC 7636 S256 check.
+func pkceMatches(codeVerifier, codeChallenge string) bool {
+	if len(codeVerifier) < 43 || len(codeVerifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(codeChallenge)) == 1
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestPKCEMatches(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	padded := base64.URLEncoding.EncodeToString(sum[:])
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"S256 match", verifier, s256(verifier), true},
+		{"longest verifier", strings.Repeat("b", 128), s256(strings.Repeat("b", 128)), true},
+		{"S256 mismatch", strings.Repeat("c", 43), s256(verifier), false},
+		{"plain method", verifier, verifier, false},
+		{"padded challenge", verifier, padded, false},
+		{"verifier too short", verifier[:42], s256(verifier[:42]), false},
+		{"verifier too long", strings.Repeat("b", 129), s256(strings.Repeat("b", 129)), false},
+		{"empty verifier", "", s256(""), false},
+		{"empty challenge", verifier, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pkceMatches(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("pkceMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
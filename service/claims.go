@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/Tommych123/auth-service/models"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+// AccessClaims is the payload of every access token. user_id duplicates sub
+// for user tokens so existing consumers keep working; tokens issued to a
+// client for itself carry sub only.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	UserID   string        `json:"user_id,omitempty"`
+	ClientID string        `json:"client_id,omitempty"`
+	Scope    string        `json:"scope,omitempty"`
+	Act      *models.Actor `json:"act,omitempty"`
+
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
+	AMR []string `json:"amr,omitempty"`
+	ACR string   `json:"acr,omitempty"`
+}
+
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthTime int64    `json:"auth_time"`
+	Nonce    string   `json:"nonce,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	ACR      string   `json:"acr,omitempty"`
+}
+
+func (s *Service) registeredClaims(subject, tokenID string, audience []string, expiresAt time.Time) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    s.issuer,
+		Subject:   subject,
+		Audience:  audience,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        tokenID,
+	}
+}
+
+// parserOptions are the checks every token signed by this service must pass.
+// The audience check is added by the caller, since introspection and
+// revocation also handle tokens minted for other services.
+func (s *Service) parserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithValidMethods(s.keys.validMethods()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithExpirationRequired(),
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/repository"
+	"github.com/google/uuid"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantAuthorizationCode = "authorization_code"
+	GrantPassword          = "password"
+)
+
+var (
+	ErrInvalidClient      = errors.New("invalid client")
+	ErrUnauthorizedClient = errors.New("grant type not allowed for client")
+	ErrInvalidScope       = errors.New("invalid scope")
+	ErrInvalidTarget      = errors.New("invalid target")
+)
+
+var supportedGrantTypes = []string{GrantRefreshToken, GrantClientCredentials, GrantAuthorizationCode, GrantDeviceCode, GrantTokenExchange, GrantPassword}
+
+type ClientRegistration struct {
+	Name            string
+	Scopes          []string
+	GrantTypes      []string
+	RedirectURIs    []string
+	Audiences       []string
+	Public          bool
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// RegisterClient stores a new client and returns its id and secret. The secret
+// is kept only as a SHA-256 hash and cannot be shown again; it is 256 random
+// bits, so a slow password hash would add nothing. Public clients
+// (browser and mobile apps) get no secret.
+func (s *Service) RegisterClient(ctx context.Context, reg ClientRegistration) (string, string, error) {
+	if reg.Name == "" {
+		return "", "", fmt.Errorf("error(RegisterClient): name is required: %w", ErrInvalidClient)
+	}
+	for _, grant := range reg.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grant) {
+			return "", "", fmt.Errorf("error(RegisterClient): unsupported grant type %q: %w", grant, ErrInvalidClient)
+		}
+	}
+	for _, grant := range []string{GrantClientCredentials, GrantTokenExchange} {
+		if reg.Public && slices.Contains(reg.GrantTypes, grant) {
+			return "", "", fmt.Errorf("error(RegisterClient): public clients cannot use %s: %w", grant, ErrInvalidClient)
+		}
+	}
+	if slices.Contains(reg.Scopes, ScopeOpenID) && !s.OIDCEnabled() {
+		return "", "", fmt.Errorf("error(RegisterClient): scope %s needs an asymmetric JWT_ALGORITHM: %w", ScopeOpenID, ErrInvalidClient)
+	}
+	if slices.Contains(reg.GrantTypes, GrantAuthorizationCode) && len(reg.RedirectURIs) == 0 {
+		return "", "", fmt.Errorf("error(RegisterClient): redirect_uris are required for %s: %w", GrantAuthorizationCode, ErrInvalidClient)
+	}
+	for _, uri := range reg.RedirectURIs {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Fragment != "" {
+			return "", "", fmt.Errorf("error(RegisterClient): invalid redirect uri %q: %w", uri, ErrInvalidClient)
+		}
+	}
+	var secret, secretHash string
+	if !reg.Public {
+		var err error
+		secret, err = generateRandomBase64(32)
+		if err != nil {
+			return "", "", fmt.Errorf("error(RegisterClient): %w", err)
+		}
+		secretHash = hashVerifier(secret)
+	}
+	client := &repository.OAuthClient{
+		ClientID:               uuid.New().String(),
+		SecretHash:             secretHash,
+		Name:                   reg.Name,
+		Scopes:                 strings.Join(reg.Scopes, " "),
+		GrantTypes:             strings.Join(reg.GrantTypes, " "),
+		RedirectURIs:           strings.Join(reg.RedirectURIs, " "),
+		Audiences:              strings.Join(reg.Audiences, " "),
+		Public:                 reg.Public,
+		AccessTokenTTLSeconds:  int(reg.AccessTokenTTL.Seconds()),
+		RefreshTokenTTLSeconds: int(reg.RefreshTokenTTL.Seconds()),
+	}
+	if err := s.repository.CreateClient(ctx, client); err != nil {
+		return "", "", fmt.Errorf("error(RegisterClient): %w", err)
+	}
+	return client.ClientID, secret, nil
+}
+
+// dummyClientSecretHash is compared against when there is no secret to
+// check, so unknown client ids take as long as wrong secrets.
+var dummyClientSecretHash = hashVerifier("")
+
+func (s *Service) AuthenticateClient(ctx context.Context, clientID, clientSecret string) (bool, error) {
+	client, err := s.repository.GetClient(ctx, clientID)
+	if err != nil {
+		return false, fmt.Errorf("error(AuthenticateClient): %w", err)
+	}
+	if client == nil || client.Public || clientSecret == "" {
+		verifierMatches(clientSecret, dummyClientSecretHash)
+		return false, nil
+	}
+	return verifierMatches(clientSecret, client.SecretHash), nil
+}
+
+// IdentifyPublicClient accepts a bare client_id, which is all a public client
+// can present.
+func (s *Service) IdentifyPublicClient(ctx context.Context, clientID string) (bool, error) {
+	client, err := s.repository.GetClient(ctx, clientID)
+	if err != nil {
+		return false, fmt.Errorf("error(IdentifyPublicClient): %w", err)
+	}
+	return client != nil && client.Public, nil
+}
+
+// ClientCredentialsToken issues a service-to-service access token whose
+// subject is the client itself. No refresh token is issued for this grant.
+func (s *Service) ClientCredentialsToken(ctx context.Context, clientID, requestedScope string) (string, time.Duration, string, error) {
+	client, err := s.repository.GetClient(ctx, clientID)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("error(ClientCredentialsToken): %w", err)
+	}
+	if client == nil {
+		return "", 0, "", fmt.Errorf("error(ClientCredentialsToken): %w", ErrInvalidClient)
+	}
+	if !clientAllowsGrant(client, GrantClientCredentials) {
+		return "", 0, "", fmt.Errorf("error(ClientCredentialsToken): %w", ErrUnauthorizedClient)
+	}
+	scope, err := resolveScope(client, requestedScope)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("error(ClientCredentialsToken): %w", err)
+	}
+	ttl := s.lifetimes.forClient(client).access
+	token, err := s.keys.signer().sign(&AccessClaims{
+		RegisteredClaims: s.registeredClaims(client.ClientID, uuid.New().String(), []string{s.audience}, time.Now().Add(ttl)),
+		ClientID:         client.ClientID,
+		Scope:            scope,
+	})
+	if err != nil {
+		return "", 0, "", fmt.Errorf("error(ClientCredentialsToken): sign token: %w", err)
+	}
+	return token, ttl, scope, nil
+}
+
+func clientAllowsGrant(client *repository.OAuthClient, grant string) bool {
+	return slices.Contains(strings.Fields(client.GrantTypes), grant)
+}
+
+// resolveScope narrows the requested scope to what the client is registered
+// for; an empty request means every registered scope.
+func resolveScope(client *repository.OAuthClient, requested string) (string, error) {
+	allowed := strings.Fields(client.Scopes)
+	if requested == "" {
+		return strings.Join(allowed, " "), nil
+	}
+	for _, scope := range strings.Fields(requested) {
+		if !slices.Contains(allowed, scope) {
+			return "", fmt.Errorf("error(resolveScope): scope %q not allowed: %w", scope, ErrInvalidScope)
+		}
+	}
+	return strings.Join(strings.Fields(requested), " "), nil
+}
+
+func (l tokenLifetimes) forClient(client *repository.OAuthClient) tokenLifetimes {
+	if client == nil {
+		return l
+	}
+	if client.AccessTokenTTLSeconds > 0 {
+		l.access = time.Duration(client.AccessTokenTTLSeconds) * time.Second
+	}
+	if client.RefreshTokenTTLSeconds > 0 {
+		l.refresh = time.Duration(client.RefreshTokenTTLSeconds) * time.Second
+	}
+	return l
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -14,19 +18,141 @@ type Config struct {
 	JWTSecret  string
 	Port       string
 	WebhookURL string
+
+	JWTAlgorithm      string
+	JWTPrivateKeyPath string
+	JWTKeyID          string
+	JWTVerifyKeys     []string
+	AdminToken        string
+
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	SessionMaxLifetime time.Duration
+
+	IssuerURL   string
+	JWTAudience string
+	JWTLeeway   time.Duration
+
+	ImpersonationPolicy []string
+
+	PasswordHash        string
+	Argon2MemoryKiB     uint32
+	Argon2Iterations    uint32
+	Argon2Parallelism   uint8
+	BcryptCost          int
+	LegacyTokenEndpoint bool
+
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	MailTransport string
+	MailFrom      string
+	MailLogPath   string
+	SMTPHost      string
+	SMTPPort      string
+	SMTPUsername  string
+	SMTPPassword  string
+	MagicLinkURL  string
+	MagicLinkTTL  time.Duration
+
+	PasswordResetURL     string
+	PasswordResetTTL     time.Duration
+	EmailVerificationURL string
+	EmailVerificationTTL time.Duration
+
+	DeviceVerificationURL string
 }
 
 func LoadEnv() *Config {
-	return &Config{
+	cfg := &Config{
 		DBHost:     getEnvRequired("DB_HOST"),
 		DBPort:     getEnvRequired("DB_PORT"),
 		DBUser:     getEnvRequired("DB_USER"),
 		DBPassword: getEnvRequired("DB_PASSWORD"),
 		DBName:     getEnvRequired("DB_NAME"),
-		JWTSecret:  getEnvRequired("JWT_SECRET"),
+		JWTSecret:  getEnv("JWT_SECRET", ""),
 		Port:       getEnvRequired("PORT"),
 		WebhookURL: getEnvRequired("WEBHOOK_URL"),
+
+		JWTAlgorithm:      getEnv("JWT_ALGORITHM", "HS512"),
+		JWTPrivateKeyPath: getEnv("JWT_PRIVATE_KEY_PATH", ""),
+		JWTKeyID:          getEnv("JWT_KEY_ID", ""),
+		JWTVerifyKeys:     getEnvList("JWT_VERIFY_KEYS"),
+		AdminToken:        getEnv("ADMIN_TOKEN", ""),
+
+		AccessTokenTTL:     getEnvDuration("ACCESS_TOKEN_TTL", 10*time.Minute),
+		RefreshTokenTTL:    getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		SessionMaxLifetime: getEnvDuration("SESSION_MAX_LIFETIME", 0),
+		JWTLeeway:          getEnvDuration("JWT_LEEWAY", 30*time.Second),
+
+		ImpersonationPolicy: getEnvList("IMPERSONATION_POLICY"),
+
+		PasswordHash:        getEnv("PASSWORD_HASH", "argon2id"),
+		Argon2MemoryKiB:     uint32(getEnvInt("ARGON2_MEMORY_KIB", 64*1024, 8, 4*1024*1024)),
+		Argon2Iterations:    uint32(getEnvInt("ARGON2_ITERATIONS", 3, 1, 100)),
+		Argon2Parallelism:   uint8(getEnvInt("ARGON2_PARALLELISM", 2, 1, 255)),
+		BcryptCost:          getEnvInt("BCRYPT_COST", 12, 10, 31),
+		LegacyTokenEndpoint: getEnvBool("LEGACY_TOKEN_ENDPOINT", false),
+
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "Auth Service"),
+		WebAuthnOrigins: getEnvList("WEBAUTHN_ORIGINS"),
+
+		MailTransport: getEnv("MAIL_TRANSPORT", ""),
+		MailFrom:      getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailLogPath:   getEnv("MAIL_LOG_PATH", ""),
+		SMTPHost:      getEnv("SMTP_HOST", ""),
+		SMTPPort:      getEnv("SMTP_PORT", "587"),
+		SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+		MagicLinkTTL:  getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute),
+
+		PasswordResetTTL:     getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		EmailVerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+	}
+	cfg.IssuerURL = strings.TrimSuffix(getEnv("ISSUER_URL", "http://localhost:"+cfg.Port), "/")
+	cfg.JWTAudience = getEnv("JWT_AUDIENCE", cfg.IssuerURL)
+	issuer, err := url.Parse(cfg.IssuerURL)
+	if err != nil || issuer.Host == "" {
+		log.Fatalf("error(LoadEnv):of validate: ISSUER_URL must be an absolute URL")
+	}
+	cfg.WebAuthnRPID = getEnv("WEBAUTHN_RP_ID", issuer.Hostname())
+	cfg.MagicLinkURL = getEnv("MAGIC_LINK_URL", "")
+	cfg.PasswordResetURL = getEnv("PASSWORD_RESET_URL", "")
+	cfg.EmailVerificationURL = getEnv("EMAIL_VERIFICATION_URL", "")
+	cfg.DeviceVerificationURL = getEnv("DEVICE_VERIFICATION_URL", "")
+	switch cfg.MailTransport {
+	case "":
+	case "smtp", "log":
+		// Links must open a page of the frontend, which posts the token to
+		// the API; the API routes themselves accept only POST.
+		if cfg.MagicLinkURL == "" || cfg.PasswordResetURL == "" || cfg.EmailVerificationURL == "" {
+			log.Fatalf("error(LoadEnv):of validate: MAGIC_LINK_URL, PASSWORD_RESET_URL and EMAIL_VERIFICATION_URL are required when MAIL_TRANSPORT is set")
+		}
+	default:
+		log.Fatalf("error(LoadEnv):of validate: unknown MAIL_TRANSPORT %q", cfg.MailTransport)
+	}
+	if cfg.MailTransport == "smtp" && cfg.SMTPHost == "" {
+		log.Fatalf("error(LoadEnv):of validate: SMTP_HOST is required for MAIL_TRANSPORT=smtp")
+	}
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{issuer.Scheme + "://" + issuer.Host}
+	}
+	if strings.HasPrefix(cfg.JWTAlgorithm, "HS") && cfg.JWTSecret == "" {
+		log.Fatalf("error(LoadEnv):of validate: JWT_SECRET is required for %v", cfg.JWTAlgorithm)
+	}
+	if !strings.HasPrefix(cfg.JWTAlgorithm, "HS") && cfg.JWTPrivateKeyPath == "" {
+		log.Fatalf("error(LoadEnv):of validate: JWT_PRIVATE_KEY_PATH is required for %v", cfg.JWTAlgorithm)
+	}
+	if cfg.PasswordHash != "argon2id" && cfg.PasswordHash != "bcrypt" {
+		log.Fatalf("error(LoadEnv):of validate: PASSWORD_HASH must be argon2id or bcrypt")
 	}
+	for _, entry := range cfg.ImpersonationPolicy {
+		if actor, subject, ok := strings.Cut(entry, "="); !ok || actor == "" || subject == "" {
+			log.Fatalf("error(LoadEnv):of validate: IMPERSONATION_POLICY entry %q", entry)
+		}
+	}
+	return cfg
 }
 
 func getEnvRequired(key string) string {
@@ -36,3 +162,57 @@ func getEnvRequired(key string) string {
 	}
 	return val
 }
+
+func getEnv(key, fallback string) string {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+	return val
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	val := getEnv(key, "")
+	if val == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		log.Fatalf("error(getEnvDuration):of validate: %v", key)
+	}
+	return d
+}
+
+func getEnvInt(key string, fallback, min, max int) int {
+	val := getEnv(key, "")
+	if val == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < min || n > max {
+		log.Fatalf("error(getEnvInt):of validate: %v must be between %d and %d", key, min, max)
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	val := getEnv(key, "")
+	if val == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Fatalf("error(getEnvBool):of validate: %v", key)
+	}
+	return b
+}
+
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/repository"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const GrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	deviceCodeInterval = 5 * time.Second
+	// userCodeAlphabet has no vowels and no look-alike characters, as
+	// suggested by RFC 8628 section 6.1.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	// Wrong user codes are limited per user and per IP address, as RFC
+	// 8628 section 5.1 asks, so that pending codes cannot be guessed.
+	userCodeMaxFailures   = 10
+	userCodeFailureWindow = 15 * time.Minute
+)
+
+var (
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("slow down")
+	ErrAccessDenied         = errors.New("access denied")
+	ErrExpiredToken         = errors.New("device code expired")
+	ErrUserCodeNotFound     = errors.New("user code not found")
+	ErrTooManyUserCodes     = errors.New("too many wrong user codes")
+)
+
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+type DeviceRequest struct {
+	UserCode   string
+	ClientID   string
+	ClientName string
+	Scope      string
+}
+
+// RequestDeviceCode starts a device authorization (RFC 8628). clientID must
+// already be authenticated (confidential) or identified (public) by the caller.
+func (s *Service) RequestDeviceCode(ctx context.Context, clientID, requestedScope string) (*DeviceAuthorization, error) {
+	client, err := s.repository.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", err)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", ErrInvalidClient)
+	}
+	if !clientAllowsGrant(client, GrantDeviceCode) {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", ErrUnauthorizedClient)
+	}
+	scope, err := resolveScope(client, requestedScope)
+	if err != nil {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", err)
+	}
+	deviceCode, selector, codeHash, err := newSplitToken()
+	if err != nil {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", err)
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", err)
+	}
+	if err := s.repository.SaveDeviceCode(ctx, &repository.DeviceCode{
+		Selector:        selector,
+		CodeHash:        codeHash,
+		UserCode:        userCode,
+		ClientID:        client.ClientID,
+		Scope:           scope,
+		IntervalSeconds: int(deviceCodeInterval.Seconds()),
+		ExpiresAt:       time.Now().Add(deviceCodeTTL),
+	}); err != nil {
+		return nil, fmt.Errorf("error(RequestDeviceCode): %w", err)
+	}
+	display := formatUserCode(userCode)
+	separator := "?"
+	if strings.Contains(s.deviceVerificationURL, "?") {
+		separator = "&"
+	}
+	return &DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         s.deviceVerificationURL,
+		VerificationURIComplete: s.deviceVerificationURL + separator + "user_code=" + display,
+		ExpiresIn:               deviceCodeTTL,
+		Interval:                deviceCodeInterval,
+	}, nil
+}
+
+// LookupDeviceCode describes a pending request so the verification page can
+// show the user which client is asking before they approve it.
+func (s *Service) LookupDeviceCode(ctx context.Context, accessToken, userCode, ip string) (*DeviceRequest, error) {
+	user, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(LookupDeviceCode): %w", err)
+	}
+	code, err := s.pendingDeviceCode(ctx, userCode, user.UserID, ip)
+	if err != nil {
+		return nil, fmt.Errorf("error(LookupDeviceCode): %w", err)
+	}
+	client, err := s.repository.GetClient(ctx, code.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("error(LookupDeviceCode): %w", err)
+	}
+	req := &DeviceRequest{UserCode: formatUserCode(code.UserCode), ClientID: code.ClientID, Scope: code.Scope}
+	if client != nil {
+		req.ClientName = client.Name
+	}
+	return req, nil
+}
+
+// DecideDeviceCode lets the user owning accessToken approve or deny the
+// device showing userCode.
+func (s *Service) DecideDeviceCode(ctx context.Context, accessToken, userCode string, approve bool, ip string) error {
+	user, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("error(DecideDeviceCode): %w", err)
+	}
+	code, err := s.pendingDeviceCode(ctx, userCode, user.UserID, ip)
+	if err != nil {
+		return fmt.Errorf("error(DecideDeviceCode): %w", err)
+	}
+	status := repository.DeviceCodeDenied
+	if approve {
+		status = repository.DeviceCodeApproved
+	}
+	decided, err := s.repository.DecideDeviceCode(ctx, code.Selector, user.UserID, strings.Join(user.AMR, " "), status)
+	if err != nil {
+		return fmt.Errorf("error(DecideDeviceCode): %w", err)
+	}
+	if !decided {
+		return fmt.Errorf("error(DecideDeviceCode): already decided: %w", ErrUserCodeNotFound)
+	}
+	return nil
+}
+
+// pendingDeviceCode looks up a code typed by the user. Once the user or
+// their IP address has typed too many wrong codes it refuses to look
+// further until the window has passed.
+func (s *Service) pendingDeviceCode(ctx context.Context, userCode, userID, ip string) (*repository.DeviceCode, error) {
+	now := time.Now()
+	windowStart := now.Add(-userCodeFailureWindow)
+	keys := []string{"user:" + userID, "ip:" + ip}
+	for _, key := range keys {
+		failures, err := s.repository.GetUserCodeFailures(ctx, key, windowStart)
+		if err != nil {
+			return nil, fmt.Errorf("error(pendingDeviceCode): %w", err)
+		}
+		if failures >= userCodeMaxFailures {
+			return nil, fmt.Errorf("error(pendingDeviceCode): %w", ErrTooManyUserCodes)
+		}
+	}
+	code, err := s.repository.GetDeviceCodeByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		return nil, fmt.Errorf("error(pendingDeviceCode): %w", err)
+	}
+	if code == nil || code.Status != repository.DeviceCodePending {
+		for _, key := range keys {
+			if _, err := s.repository.CountUserCodeFailure(ctx, key, now, windowStart); err != nil {
+				return nil, fmt.Errorf("error(pendingDeviceCode): %w", err)
+			}
+		}
+		return nil, fmt.Errorf("error(pendingDeviceCode): %w", ErrUserCodeNotFound)
+	}
+	return code, nil
+}
+
+// ExchangeDeviceCode answers a device polling the token endpoint. Until the
+// user decides it returns ErrAuthorizationPending; polling faster than the
+// interval returns ErrSlowDown and stretches the interval by 5 seconds.
+func (s *Service) ExchangeDeviceCode(ctx context.Context, deviceCode, clientID string, req TokenRequest) (*TokenPair, error) {
+	selector, verifier, ok := parseSplitToken(deviceCode)
+	if !ok {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): malformed device code: %w", ErrInvalidGrant)
+	}
+	code, err := s.repository.GetDeviceCode(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", err)
+	}
+	if code == nil || !verifierMatches(verifier, code.CodeHash) || code.Used {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): device code not found or used: %w", ErrInvalidGrant)
+	}
+	if code.ClientID != clientID {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): client mismatch: %w", ErrInvalidGrant)
+	}
+	now := time.Now()
+	if now.After(code.ExpiresAt) {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", ErrExpiredToken)
+	}
+	interval := code.IntervalSeconds
+	tooFast := code.LastPolledAt.Valid && now.Sub(code.LastPolledAt.Time) < time.Duration(interval)*time.Second
+	if tooFast {
+		interval += 5
+	}
+	if err := s.repository.TouchDeviceCode(ctx, selector, now, interval); err != nil {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", err)
+	}
+	if tooFast {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", ErrSlowDown)
+	}
+	switch code.Status {
+	case repository.DeviceCodePending:
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", ErrAuthorizationPending)
+	case repository.DeviceCodeDenied:
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", ErrAccessDenied)
+	}
+	redeemed, err := s.repository.MarkDeviceCodeUsed(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): %w", err)
+	}
+	if !redeemed {
+		return nil, fmt.Errorf("error(ExchangeDeviceCode): device code already used: %w", ErrInvalidGrant)
+	}
+	req.UserID = code.UserID
+	req.ClientID = code.ClientID
+	req.Scope = code.Scope
+	req.AMR = strings.Fields(code.AMR)
+	return s.IssueTokens(ctx, req)
+}
+
+func generateUserCode() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for range userCodeLength {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("error(generateUserCode): rand read failed: %w", err)
+		}
+		b.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeUserCode accepts the code the way people type it: any case, with
+// or without the dash and spaces.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+func formatUserCode(userCode string) string {
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
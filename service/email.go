@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/pkg/mailer"
+	"github.com/Tommych123/auth-service/repository"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AMREmail marks a login proven by a link sent to the user's mailbox. RFC
+// 8176 has no value for it.
+const AMREmail = "email"
+
+const EventPasswordReset = "password_reset"
+
+const mailTimeout = 30 * time.Second
+
+var (
+	ErrInvalidEmailToken    = errors.New("invalid email token")
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+)
+
+// emailLink is where a link of one purpose points and how long it works.
+type emailLink struct {
+	url string
+	ttl time.Duration
+}
+
+type emailLinks struct {
+	login         emailLink
+	passwordReset emailLink
+	verification  emailLink
+}
+
+// RequestMagicLink mails a single-use sign-in link to a registered address.
+// The lookup, the token and the mail all happen in the background and
+// unknown addresses are silently ignored, so neither the answer nor its
+// timing tells the caller which emails have accounts.
+func (s *Service) RequestMagicLink(email string) {
+	s.inBackground("RequestMagicLink", func(ctx context.Context) error {
+		return s.mailLinkTo(ctx, email, repository.EmailTokenLogin, s.links.login, "Your sign-in link",
+			"Open this link to sign in:\n\n%s\n\nThe link works once and expires in %s. If you did not ask to sign in, ignore this email.\n")
+	})
+}
+
+// RedeemMagicLink signs in the user the link was sent to. Users with a
+// second factor get an MFA challenge, as after a password.
+func (s *Service) RedeemMagicLink(ctx context.Context, token string, req TokenRequest) (*LoginResult, error) {
+	userID, err := s.consumeEmailToken(ctx, token, repository.EmailTokenLogin)
+	if err != nil {
+		return nil, fmt.Errorf("error(RedeemMagicLink): %w", err)
+	}
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error(RedeemMagicLink): %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("error(RedeemMagicLink): %w", ErrInvalidEmailToken)
+	}
+	// Following the link proves the user reads this mailbox.
+	if err := s.repository.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("error(RedeemMagicLink): %w", err)
+	}
+	amr := []string{AMREmail}
+	if user.TOTPEnabled {
+		mfaToken, err := s.beginMFA(ctx, user.ID, amr)
+		if err != nil {
+			return nil, fmt.Errorf("error(RedeemMagicLink): %w", err)
+		}
+		return &LoginResult{MFAToken: mfaToken, MFAExpiresIn: mfaChallengeTTL}, nil
+	}
+	req.UserID = user.ID
+	req.AMR = amr
+	pair, err := s.IssueTokens(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error(RedeemMagicLink): %w", err)
+	}
+	return &LoginResult{Tokens: pair}, nil
+}
+
+// RequestPasswordReset mails a single-use reset link to a registered
+// address. Like RequestMagicLink it works in the background and does not
+// reveal whether the address has an account.
+func (s *Service) RequestPasswordReset(email string) {
+	s.inBackground("RequestPasswordReset", func(ctx context.Context) error {
+		return s.mailLinkTo(ctx, email, repository.EmailTokenPasswordReset, s.links.passwordReset, "Reset your password",
+			"Open this link to choose a new password:\n\n%s\n\nThe link works once and expires in %s. If you did not ask for a reset, ignore this email; your password stays the same.\n")
+	})
+}
+
+// mailLinkTo is sendEmailLink for an address that may have no account, in
+// which case nothing is sent.
+func (s *Service) mailLinkTo(ctx context.Context, email, purpose string, link emailLink, subject, body string) error {
+	user, err := s.repository.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return fmt.Errorf("error(mailLinkTo): %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+	if err := s.sendEmailLink(ctx, user, purpose, link, subject, body); err != nil {
+		return fmt.Errorf("error(mailLinkTo): %w", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a token from a reset link. Every
+// session of the user is revoked, so whoever knew the old password is
+// signed out, and pending MFA challenges and unused emailed links are
+// dropped with the old password; a second factor, if any, stays enabled.
+func (s *Service) ResetPassword(ctx context.Context, token, password, ip string) error {
+	// Check the password first so a rejected one does not burn the link.
+	if err := validatePassword(password); err != nil {
+		return fmt.Errorf("error(ResetPassword): %w", err)
+	}
+	userID, err := s.consumeEmailToken(ctx, token, repository.EmailTokenPasswordReset)
+	if err != nil {
+		return fmt.Errorf("error(ResetPassword): %w", err)
+	}
+	hash, err := s.passwords.hash(password)
+	if err != nil {
+		return fmt.Errorf("error(ResetPassword): %w", err)
+	}
+	if err := s.repository.ResetPassword(ctx, userID, hash); err != nil {
+		return fmt.Errorf("error(ResetPassword): %w", err)
+	}
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		return fmt.Errorf("error(ResetPassword): %w", err)
+	}
+	s.emitSecurityEvent(EventPasswordReset, userID, ip)
+	return nil
+}
+
+// SendEmailVerification mails a verification link to the address of the
+// user owning accessToken.
+func (s *Service) SendEmailVerification(ctx context.Context, accessToken string) error {
+	user, err := s.tokenUser(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("error(SendEmailVerification): %w", err)
+	}
+	if user.EmailVerified {
+		return fmt.Errorf("error(SendEmailVerification): %w", ErrEmailAlreadyVerified)
+	}
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		return fmt.Errorf("error(SendEmailVerification): %w", err)
+	}
+	return nil
+}
+
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.consumeEmailToken(ctx, token, repository.EmailTokenVerification)
+	if err != nil {
+		return fmt.Errorf("error(VerifyEmail): %w", err)
+	}
+	if err := s.repository.MarkEmailVerified(ctx, userID); err != nil {
+		return fmt.Errorf("error(VerifyEmail): %w", err)
+	}
+	return nil
+}
+
+func (s *Service) sendVerificationEmail(ctx context.Context, user *repository.User) error {
+	return s.sendEmailLink(ctx, user, repository.EmailTokenVerification, s.links.verification, "Confirm your email",
+		"Open this link to confirm your email address:\n\n%s\n\nThe link works once and expires in %s.\n")
+}
+
+// sendEmailLink mails the user a link carrying a new token for purpose.
+// body gets the link and its lifetime.
+func (s *Service) sendEmailLink(ctx context.Context, user *repository.User, purpose string, link emailLink, subject, body string) error {
+	token, err := s.newEmailToken(ctx, user.ID, purpose, link.ttl)
+	if err != nil {
+		return fmt.Errorf("error(sendEmailLink): %w", err)
+	}
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, linkWithToken(link.url, token), link.ttl),
+	})
+	return nil
+}
+
+// newEmailToken stores a split token for purpose, hashed like a refresh
+// token, and returns it for the email.
+func (s *Service) newEmailToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	token, selector, tokenHash, err := newSplitToken()
+	if err != nil {
+		return "", fmt.Errorf("error(newEmailToken): %w", err)
+	}
+	err = s.repository.SaveEmailToken(ctx, &repository.EmailToken{
+		Selector:  selector,
+		TokenHash: tokenHash,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error(newEmailToken): %w", err)
+	}
+	return token, nil
+}
+
+// consumeEmailToken burns an unexpired token of purpose and returns the
+// user it was sent to.
+func (s *Service) consumeEmailToken(ctx context.Context, token, purpose string) (string, error) {
+	selector, verifier, ok := parseSplitToken(token)
+	if !ok {
+		return "", fmt.Errorf("error(consumeEmailToken): malformed token: %w", ErrInvalidEmailToken)
+	}
+	row, err := s.repository.GetEmailToken(ctx, selector)
+	if err != nil {
+		return "", fmt.Errorf("error(consumeEmailToken): %w", err)
+	}
+	if row == nil || row.Purpose != purpose || !verifierMatches(verifier, row.TokenHash) || row.Used || time.Now().After(row.ExpiresAt) {
+		return "", fmt.Errorf("error(consumeEmailToken): token not found, used or expired: %w", ErrInvalidEmailToken)
+	}
+	used, err := s.repository.UseEmailToken(ctx, selector)
+	if err != nil {
+		return "", fmt.Errorf("error(consumeEmailToken): %w", err)
+	}
+	if !used {
+		return "", fmt.Errorf("error(consumeEmailToken): token already used: %w", ErrInvalidEmailToken)
+	}
+	return row.UserID, nil
+}
+
+func (s *Service) sendMail(msg mailer.Message) {
+	s.inBackground("sendMail", func(ctx context.Context) error {
+		return s.mailer.Send(ctx, msg)
+	})
+}
+
+// inBackground runs fn off the request path with its own deadline and logs
+// its error under name.
+func (s *Service) inBackground(name string, fn func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			log.Printf("error(%s): %v", name, err)
+		}
+	}()
+}
+
+func linkWithToken(base, token string) string {
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(token)
+}
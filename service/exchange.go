@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/repository"
+	"github.com/google/uuid"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	GrantTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+
+	EventImpersonation = "impersonation"
+)
+
+var ErrExchangeDenied = errors.New("token exchange denied by policy")
+
+type TokenExchangeRequest struct {
+	ClientID           string
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedSubject   string
+	RequestedTokenType string
+	Scope              string
+	Audience           string
+	IP                 string
+}
+
+type ExchangedToken struct {
+	AccessToken     string
+	IssuedTokenType string
+	ExpiresIn       time.Duration
+	Scope           string
+}
+
+// impersonationPolicy maps an actor user_id to the user_ids it may
+// impersonate; "*" stands for any user.
+type impersonationPolicy map[string][]string
+
+func newImpersonationPolicy(entries []string) impersonationPolicy {
+	policy := impersonationPolicy{}
+	for _, entry := range entries {
+		actor, subject, _ := strings.Cut(entry, "=")
+		policy[actor] = append(policy[actor], subject)
+	}
+	return policy
+}
+
+func (p impersonationPolicy) allows(actor, subject string) bool {
+	return actor != subject && (slices.Contains(p[actor], subject) || slices.Contains(p[actor], "*"))
+}
+
+// ExchangeToken implements the RFC 8693 token exchange grant in two modes:
+//
+//   - delegation: the client (or the holder of actor_token) calls on behalf of
+//     the subject_token's user; the new token keeps that user as subject;
+//   - impersonation: requested_subject names another user and the
+//     subject_token's user becomes the actor, if the policy allows it. The
+//     subject_token must then be a plain user token issued to the requesting
+//     client for this service, so a token replayed by another client or
+//     service, or one that is itself delegated, cannot start it.
+//
+// Either way the result is a down-scoped access token whose act claim records
+// the actor, which never outlives the subject token. Every decision made after
+// the tokens are validated is written to the audit log.
+func (s *Service) ExchangeToken(ctx context.Context, req TokenExchangeRequest) (*ExchangedToken, error) {
+	client, err := s.repository.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeToken): %w", err)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("error(ExchangeToken): %w", ErrInvalidClient)
+	}
+	if !clientAllowsGrant(client, GrantTokenExchange) {
+		return nil, fmt.Errorf("error(ExchangeToken): %w", ErrUnauthorizedClient)
+	}
+	if !isJWTTokenType(req.SubjectTokenType) || (req.RequestedTokenType != "" && !isJWTTokenType(req.RequestedTokenType)) {
+		return nil, fmt.Errorf("error(ExchangeToken): unsupported token type: %w", ErrInvalidRequest)
+	}
+	subject, err := s.parseExchangeToken(ctx, req.SubjectToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeToken): subject_token: %w", err)
+	}
+	subjectID := subject.UserID
+	if subjectID == "" {
+		return nil, fmt.Errorf("error(ExchangeToken): subject_token has no user: %w", ErrInvalidRequest)
+	}
+	audit := &repository.TokenExchange{
+		ClientID:  client.ClientID,
+		SubjectID: subjectID,
+		ActorID:   client.ClientID,
+		Audience:  req.Audience,
+		IPAddress: req.IP,
+	}
+	act := &models.Actor{Sub: client.ClientID, ClientID: client.ClientID}
+	switch {
+	case req.RequestedSubject != "" && req.ActorToken != "":
+		return nil, fmt.Errorf("error(ExchangeToken): actor_token cannot be combined with requested_subject: %w", ErrInvalidRequest)
+	case req.RequestedSubject != "":
+		audit.Impersonation = true
+		audit.ActorID = subjectID
+		audit.SubjectID = req.RequestedSubject
+		act = &models.Actor{Sub: subjectID, ClientID: client.ClientID}
+		switch {
+		case !slices.Contains(subject.Audience, s.audience):
+			return nil, s.denyExchange(ctx, audit, "subject_token not issued for this service", ErrInvalidRequest)
+		case subject.ClientID != client.ClientID:
+			return nil, s.denyExchange(ctx, audit, "subject_token issued to another client", ErrExchangeDenied)
+		case subject.Act != nil:
+			return nil, s.denyExchange(ctx, audit, "subject_token is delegated", ErrExchangeDenied)
+		}
+	case req.ActorToken != "":
+		if !isJWTTokenType(req.ActorTokenType) {
+			return nil, fmt.Errorf("error(ExchangeToken): unsupported actor_token_type: %w", ErrInvalidRequest)
+		}
+		actor, err := s.parseExchangeToken(ctx, req.ActorToken)
+		if err != nil {
+			return nil, fmt.Errorf("error(ExchangeToken): actor_token: %w", err)
+		}
+		audit.ActorID = actor.Subject
+		act = &models.Actor{Sub: actor.Subject, ClientID: actor.ClientID, Act: actor.Act}
+	}
+	if !audit.Impersonation {
+		act = appendActors(act, subject.Act)
+	}
+
+	if audit.Impersonation && !s.impersonation.allows(audit.ActorID, audit.SubjectID) {
+		return nil, s.denyExchange(ctx, audit, "impersonation not allowed", ErrExchangeDenied)
+	}
+	// Without an explicit scope the new token keeps the subject_token's scope;
+	// a subject_token with no scope can only be exchanged for one without.
+	requested := req.Scope
+	if requested == "" {
+		requested = subject.Scope
+	}
+	if !scopeWithin(requested, subject.Scope) {
+		return nil, s.denyExchange(ctx, audit, "scope exceeds subject_token", ErrInvalidScope)
+	}
+	var scope string
+	if requested != "" {
+		scope, err = resolveScope(client, requested)
+		if err != nil {
+			return nil, s.denyExchange(ctx, audit, "scope not allowed for client", err)
+		}
+	}
+	audit.Scope = scope
+	if req.Audience != "" && req.Audience != s.audience && !slices.Contains(strings.Fields(client.Audiences), req.Audience) {
+		return nil, s.denyExchange(ctx, audit, "audience not allowed for client", ErrInvalidTarget)
+	}
+
+	ttl := s.lifetimes.forClient(client).access
+	if remaining := time.Until(subject.ExpiresAt.Time); remaining < ttl {
+		ttl = remaining
+	}
+	audience := []string{s.audience}
+	if req.Audience != "" {
+		audience = []string{req.Audience}
+	}
+	roles, permissions, err := s.repository.GetUserAuthorization(ctx, audit.SubjectID)
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeToken): %w", err)
+	}
+	audit.TokenID = uuid.New().String()
+	token, err := s.keys.signer().sign(&AccessClaims{
+		RegisteredClaims: s.registeredClaims(audit.SubjectID, audit.TokenID, audience, time.Now().Add(ttl)),
+		UserID:           audit.SubjectID,
+		ClientID:         client.ClientID,
+		Scope:            scope,
+		Act:              act,
+		Roles:            roles,
+		Permissions:      permissions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error(ExchangeToken): sign token: %w", err)
+	}
+	audit.Granted = true
+	if err := s.repository.SaveTokenExchange(ctx, audit); err != nil {
+		return nil, fmt.Errorf("error(ExchangeToken): %w", err)
+	}
+	if audit.Impersonation {
+		s.emitSecurityEvent(EventImpersonation, audit.SubjectID, req.IP)
+	}
+	return &ExchangedToken{
+		AccessToken:     token,
+		IssuedTokenType: TokenTypeAccessToken,
+		ExpiresIn:       ttl,
+		Scope:           scope,
+	}, nil
+}
+
+// parseExchangeToken validates a subject or actor token. Any audience is
+// accepted so exchanged tokens can be exchanged again down a call chain;
+// RFC 8693 wants invalid_request when validation fails.
+func (s *Service) parseExchangeToken(ctx context.Context, token string) (*AccessClaims, error) {
+	claims, err := s.parseIssuedAccessToken(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		return nil, fmt.Errorf("error(parseExchangeToken): %w: %w", err, ErrInvalidRequest)
+	}
+	return claims, err
+}
+
+func (s *Service) denyExchange(ctx context.Context, audit *repository.TokenExchange, reason string, cause error) error {
+	audit.Reason = reason
+	if err := s.repository.SaveTokenExchange(ctx, audit); err != nil {
+		log.Printf("error(denyExchange):audit token exchange %v", err)
+	}
+	return fmt.Errorf("error(ExchangeToken): %s: %w", reason, cause)
+}
+
+// appendActors returns a copy of the chain current with prior nested below
+// its innermost actor, so that no actor of either chain is lost.
+func appendActors(current, prior *models.Actor) *models.Actor {
+	if current == nil {
+		return prior
+	}
+	actor := *current
+	actor.Act = appendActors(current.Act, prior)
+	return &actor
+}
+
+func isJWTTokenType(tokenType string) bool {
+	return tokenType == TokenTypeAccessToken || tokenType == TokenTypeJWT
+}
+
+func scopeWithin(scope, allowed string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(strings.Fields(allowed), s) {
+			return false
+		}
+	}
+	return true
+}
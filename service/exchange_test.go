@@ -0,0 +1,84 @@
+package service
+
+import (
+	"github.com/Tommych123/auth-service/models"
+	"reflect"
+	"testing"
+)
+
+func TestImpersonationPolicyAllows(t *testing.T) {
+	policy := newImpersonationPolicy([]string{"support=alice", "support=bob", "admin=*"})
+	tests := []struct {
+		name    string
+		actor   string
+		subject string
+		want    bool
+	}{
+		{"listed subject", "support", "alice", true},
+		{"second listed subject", "support", "bob", true},
+		{"unlisted subject", "support", "carol", false},
+		{"wildcard", "admin", "carol", true},
+		{"unknown actor", "alice", "bob", false},
+		{"self", "admin", "admin", false},
+		{"empty actor", "", "alice", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.allows(tt.actor, tt.subject); got != tt.want {
+				t.Errorf("allows(%q, %q) = %v, want %v", tt.actor, tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeWithin(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   string
+		allowed string
+		want    bool
+	}{
+		{"same", "read write", "read write", true},
+		{"narrower", "read", "read write", true},
+		{"empty", "", "read", true},
+		{"empty from empty", "", "", true},
+		{"escalation", "read admin", "read write", false},
+		{"anything from empty", "read", "", false},
+		{"prefix is not a scope", "rea", "read", false},
+		{"extra spaces", "  read   write ", "write read", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scopeWithin(tt.scope, tt.allowed); got != tt.want {
+				t.Errorf("scopeWithin(%q, %q) = %v, want %v", tt.scope, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendActors(t *testing.T) {
+	prior := &models.Actor{Sub: "gateway", ClientID: "gateway", Act: &models.Actor{Sub: "frontend"}}
+	tests := []struct {
+		name    string
+		current *models.Actor
+		prior   *models.Actor
+		want    *models.Actor
+	}{
+		{"no prior chain", &models.Actor{Sub: "billing"}, nil, &models.Actor{Sub: "billing"}},
+		{"prior chain nested", &models.Actor{Sub: "billing"}, prior,
+			&models.Actor{Sub: "billing", Act: prior}},
+		{"both chains kept", &models.Actor{Sub: "billing", Act: &models.Actor{Sub: "batch"}}, prior,
+			&models.Actor{Sub: "billing", Act: &models.Actor{Sub: "batch", Act: prior}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := *tt.current
+			if got := appendActors(tt.current, tt.prior); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("appendActors() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(*tt.current, original) {
+				t.Error("appendActors modified its argument")
+			}
+		})
+	}
+}
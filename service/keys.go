@@ -0,0 +1,268 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+type SigningKey struct {
+	ID        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyRing is built once at startup and never changes afterwards, so it is
+// safe for concurrent use without locking.
+type KeyRing struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeyRing(active *SigningKey, verifyOnly ...*SigningKey) (*KeyRing, error) {
+	ring := &KeyRing{
+		active: active,
+		keys:   map[string]*SigningKey{active.ID: active},
+	}
+	for _, key := range verifyOnly {
+		if _, ok := ring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("error(NewKeyRing): duplicate kid %q", key.ID)
+		}
+		ring.keys[key.ID] = key
+	}
+	return ring, nil
+}
+
+// LoadKeyRing builds the ring from config: the active key signs, verifyKeys
+// ("kid=secret" for HMAC, "kid=/path/public.pem" otherwise) are accepted only
+// for verification until they are removed from config. A retired asymmetric
+// key is configured by its public key, so its private key can be destroyed.
+func LoadKeyRing(algorithm, secret, privateKeyPath, keyID string, verifyKeys []string) (*KeyRing, error) {
+	active, err := LoadSigningKey(algorithm, secret, privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != "" {
+		active.ID = keyID
+	}
+	var previous []*SigningKey
+	for _, entry := range verifyKeys {
+		kid, value, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || value == "" {
+			return nil, fmt.Errorf("error(LoadKeyRing): invalid verify key entry %q", entry)
+		}
+		key, err := loadVerifyKey(algorithm, value)
+		if err != nil {
+			return nil, fmt.Errorf("error(LoadKeyRing): load key %q: %w", kid, err)
+		}
+		key.ID = kid
+		previous = append(previous, key)
+	}
+	return NewKeyRing(active, previous...)
+}
+
+func LoadSigningKey(algorithm, secret, privateKeyPath string) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("error(LoadSigningKey): unsupported algorithm %q", algorithm)
+	}
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if secret == "" {
+			return nil, fmt.Errorf("error(LoadSigningKey): secret is required for %s", algorithm)
+		}
+		key := &SigningKey{method: method, signKey: []byte(secret), verifyKey: []byte(secret)}
+		key.ID = key.thumbprint()
+		return key, nil
+	}
+	if privateKeyPath == "" {
+		return nil, fmt.Errorf("error(LoadSigningKey): private key path is required for %s", algorithm)
+	}
+	pemData, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error(LoadSigningKey): read private key: %w", err)
+	}
+	key, err := parseSigningKey(method, pemData)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = key.thumbprint()
+	return key, nil
+}
+
+// loadVerifyKey loads a key that only verifies tokens: the shared secret for
+// HMAC, otherwise a PEM encoded public key read from value.
+func loadVerifyKey(algorithm, value string) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("error(loadVerifyKey): unsupported algorithm %q", algorithm)
+	}
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		return &SigningKey{method: method, verifyKey: []byte(value)}, nil
+	}
+	pemData, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("error(loadVerifyKey): read public key: %w", err)
+	}
+	return parseVerifyKey(method, pemData)
+}
+
+func parseVerifyKey(method jwt.SigningMethod, pemData []byte) (*SigningKey, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("error(parseVerifyKey): parse RSA public key: %w", err)
+		}
+		return &SigningKey{method: method, verifyKey: key}, nil
+	case *jwt.SigningMethodECDSA:
+		key, err := jwt.ParseECPublicKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("error(parseVerifyKey): parse EC public key: %w", err)
+		}
+		if key.Curve.Params().BitSize != method.(*jwt.SigningMethodECDSA).CurveBits {
+			return nil, fmt.Errorf("error(parseVerifyKey): EC key curve does not match %s", method.Alg())
+		}
+		return &SigningKey{method: method, verifyKey: key}, nil
+	case *jwt.SigningMethodEd25519:
+		key, err := jwt.ParseEdPublicKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("error(parseVerifyKey): parse Ed25519 public key: %w", err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("error(parseVerifyKey): not an Ed25519 key")
+		}
+		return &SigningKey{method: method, verifyKey: edKey}, nil
+	}
+	return nil, fmt.Errorf("error(parseVerifyKey): unsupported algorithm %q", method.Alg())
+}
+
+func parseSigningKey(method jwt.SigningMethod, pemData []byte) (*SigningKey, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("error(parseSigningKey): parse RSA key: %w", err)
+		}
+		return &SigningKey{method: method, signKey: key, verifyKey: &key.PublicKey}, nil
+	case *jwt.SigningMethodECDSA:
+		key, err := jwt.ParseECPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("error(parseSigningKey): parse EC key: %w", err)
+		}
+		if key.Curve.Params().BitSize != method.(*jwt.SigningMethodECDSA).CurveBits {
+			return nil, fmt.Errorf("error(parseSigningKey): EC key curve does not match %s", method.Alg())
+		}
+		return &SigningKey{method: method, signKey: key, verifyKey: &key.PublicKey}, nil
+	case *jwt.SigningMethodEd25519:
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("error(parseSigningKey): parse Ed25519 key: %w", err)
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("error(parseSigningKey): not an Ed25519 key")
+		}
+		return &SigningKey{method: method, signKey: edKey, verifyKey: edKey.Public()}, nil
+	}
+	return nil, fmt.Errorf("error(parseSigningKey): unsupported algorithm %q", method.Alg())
+}
+
+func (k *SigningKey) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.signKey)
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint for asymmetric keys and a digest
+// of the secret for HMAC keys, so a kid never has to be configured by hand.
+func (k *SigningKey) thumbprint() string {
+	jwk, ok := k.jwk()
+	if !ok {
+		sum := sha256.Sum256(k.verifyKey.([]byte))
+		return base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+	var members string
+	switch jwk.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (r *KeyRing) signer() *SigningKey {
+	return r.active
+}
+
+func (r *KeyRing) asymmetric() bool {
+	_, hmac := r.active.method.(*jwt.SigningMethodHMAC)
+	return !hmac
+}
+
+func (r *KeyRing) validMethods() []string {
+	return []string{r.signer().method.Alg()}
+}
+
+func (r *KeyRing) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := r.active
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = r.keys[kid]; !ok {
+			return nil, fmt.Errorf("error(keyFunc): unknown kid %q", kid)
+		}
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("error(keyFunc): unexpected signing method %s", token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+func (r *KeyRing) JWKS() models.JWKSet {
+	set := models.JWKSet{Keys: []models.JWK{}}
+	for kid, key := range r.keys {
+		if jwk, ok := key.jwk(); ok {
+			jwk.Kid = kid
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func (k *SigningKey) jwk() (models.JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := models.JWK{Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return models.JWK{}, false
+	}
+	return jwk, true
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newECKeyFiles(t *testing.T, curve elliptic.Curve) (privatePath, publicPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "private.pem", "EC PRIVATE KEY", priv), writePEM(t, "public.pem", "PUBLIC KEY", pub)
+}
+
+func TestLoadKeyRingRetiredPublicKey(t *testing.T) {
+	activePath, _ := newECKeyFiles(t, elliptic.P256())
+	retiredPrivate, retiredPublic := newECKeyFiles(t, elliptic.P256())
+
+	ring, err := LoadKeyRing("ES256", "", activePath, "", []string{"old=" + retiredPublic})
+	if err != nil {
+		t.Fatalf("LoadKeyRing: %v", err)
+	}
+	retired, err := LoadSigningKey("ES256", "", retiredPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retired.ID = "old"
+	token, err := retired.sign(jwt.MapClaims{"sub": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(token, ring.keyFunc, jwt.WithValidMethods(ring.validMethods())); err != nil {
+		t.Errorf("token of retired key rejected: %v", err)
+	}
+	if len(ring.JWKS().Keys) != 2 {
+		t.Errorf("JWKS has %d keys, want 2", len(ring.JWKS().Keys))
+	}
+
+	retired.ID = "unknown"
+	token, _ = retired.sign(jwt.MapClaims{"sub": "user"})
+	if _, err := jwt.Parse(token, ring.keyFunc, jwt.WithValidMethods(ring.validMethods())); err == nil {
+		t.Error("token with unknown kid accepted")
+	}
+}
+
+func TestLoadKeyRingVerifyKeyErrors(t *testing.T) {
+	activePath, _ := newECKeyFiles(t, elliptic.P256())
+	retiredPrivate, _ := newECKeyFiles(t, elliptic.P256())
+	_, p384Public := newECKeyFiles(t, elliptic.P384())
+
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{"private key instead of public", "old=" + retiredPrivate},
+		{"curve mismatch", "old=" + p384Public},
+		{"missing file", "old=" + filepath.Join(t.TempDir(), "none.pem")},
+		{"no kid", "=" + retiredPrivate},
+		{"no value", "old="},
+		{"no separator", "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKeyRing("ES256", "", activePath, "", []string{tt.entry}); err == nil {
+				t.Errorf("LoadKeyRing(%q) succeeded", tt.entry)
+			}
+		})
+	}
+}
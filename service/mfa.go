@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/repository"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Authentication method references (RFC 8176) and assurance levels put into
+// the amr and acr claims. Recovery codes are one-time passwords too.
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	mfaMaxAttempts          = 5
+	mfaLockout              = 15 * time.Minute
+	recoveryCodeCount       = 10
+)
+
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrMFAAlreadyEnabled    = errors.New("mfa already enabled")
+	ErrMFANotEnrolled       = errors.New("mfa not enrolled")
+	ErrInvalidMFACode       = errors.New("invalid mfa code")
+	ErrInvalidMFAChallenge  = errors.New("invalid mfa challenge")
+	ErrMFARequired          = errors.New("mfa required")
+	ErrMFALocked            = errors.New("too many wrong mfa codes")
+	recoveryCodeEncoding    = base32.StdEncoding.WithPadding(base32.NoPadding)
+	recoveryCodeReplacement = strings.NewReplacer("-", "", " ", "")
+)
+
+// LoginResult holds either the tokens or, when the user has a second factor,
+// the MFA token to present to CompleteMFALogin.
+type LoginResult struct {
+	Tokens       *TokenPair
+	MFAToken     string
+	MFAExpiresIn time.Duration
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+func acrFor(amr []string) string {
+	switch {
+	case len(amr) == 0:
+		return ""
+	case slices.Contains(amr, AMRMFA):
+		return ACRMultiFactor
+	}
+	return ACRSingleFactor
+}
+
+// PasswordLogin is the first login step. Users without a second factor get
+// their tokens straight away.
+func (s *Service) PasswordLogin(ctx context.Context, email, password string, req TokenRequest) (*LoginResult, error) {
+	user, err := s.authenticatePassword(ctx, email, password)
+	if err != nil {
+		return nil, fmt.Errorf("error(PasswordLogin): %w", err)
+	}
+	amr := []string{AMRPassword}
+	if user.TOTPEnabled {
+		token, err := s.beginMFA(ctx, user.ID, amr)
+		if err != nil {
+			return nil, fmt.Errorf("error(PasswordLogin): %w", err)
+		}
+		return &LoginResult{MFAToken: token, MFAExpiresIn: mfaChallengeTTL}, nil
+	}
+	req.UserID = user.ID
+	req.AMR = amr
+	pair, err := s.IssueTokens(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error(PasswordLogin): %w", err)
+	}
+	return &LoginResult{Tokens: pair}, nil
+}
+
+func (s *Service) beginMFA(ctx context.Context, userID string, amr []string) (string, error) {
+	token, selector, tokenHash, err := newSplitToken()
+	if err != nil {
+		return "", fmt.Errorf("error(beginMFA): %w", err)
+	}
+	if err := s.repository.SaveMFAChallenge(ctx, &repository.MFAChallenge{
+		Selector:  selector,
+		TokenHash: tokenHash,
+		UserID:    userID,
+		AMR:       strings.Join(amr, " "),
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}); err != nil {
+		return "", fmt.Errorf("error(beginMFA): %w", err)
+	}
+	return token, nil
+}
+
+// CompleteMFALogin is the second login step: a TOTP code or a recovery code
+// for the MFA token from the first step. A challenge allows a few wrong codes
+// and is single use; the user's lockout applies on top, so a fresh challenge
+// from /login buys no extra guesses.
+func (s *Service) CompleteMFALogin(ctx context.Context, mfaToken, code, recoveryCode string, req TokenRequest) (*TokenPair, error) {
+	selector, verifier, ok := parseSplitToken(mfaToken)
+	if !ok {
+		return nil, fmt.Errorf("error(CompleteMFALogin): malformed token: %w", ErrInvalidMFAChallenge)
+	}
+	challenge, err := s.repository.GetMFAChallenge(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error(CompleteMFALogin): %w", err)
+	}
+	if challenge == nil || !verifierMatches(verifier, challenge.TokenHash) || challenge.Used ||
+		challenge.Attempts >= mfaChallengeMaxAttempts || time.Now().After(challenge.ExpiresAt) {
+		return nil, fmt.Errorf("error(CompleteMFALogin): challenge not found, used or expired: %w", ErrInvalidMFAChallenge)
+	}
+	user, err := s.repository.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error(CompleteMFALogin): %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("error(CompleteMFALogin): %w", ErrInvalidMFAChallenge)
+	}
+	if err := s.checkSecondFactor(ctx, user, code, recoveryCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if _, err := s.repository.CountMFAChallengeAttempt(ctx, selector, mfaChallengeMaxAttempts); err != nil {
+				return nil, fmt.Errorf("error(CompleteMFALogin): %w", err)
+			}
+		}
+		return nil, fmt.Errorf("error(CompleteMFALogin): %w", err)
+	}
+	completed, err := s.repository.MarkMFAChallengeUsed(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error(CompleteMFALogin): %w", err)
+	}
+	if !completed {
+		return nil, fmt.Errorf("error(CompleteMFALogin): challenge already used: %w", ErrInvalidMFAChallenge)
+	}
+	req.UserID = user.ID
+	req.AMR = append(strings.Fields(challenge.AMR), AMROTP, AMRMFA)
+	return s.IssueTokens(ctx, req)
+}
+
+// checkSecondFactor is the only way a TOTP or recovery code of an enrolled
+// user is accepted, whether at login, on the authorize page or when turning
+// TOTP off. Attempts are counted per user: after mfaMaxAttempts without a
+// correct code every check fails with ErrMFALocked for mfaLockout.
+func (s *Service) checkSecondFactor(ctx context.Context, user *repository.User, code, recoveryCode string) error {
+	if err := s.countMFAAttempt(ctx, user.ID); err != nil {
+		return fmt.Errorf("error(checkSecondFactor): %w", err)
+	}
+	valid, err := s.verifySecondFactor(ctx, user, code, recoveryCode)
+	if err != nil {
+		return fmt.Errorf("error(checkSecondFactor): %w", err)
+	}
+	if !valid {
+		return fmt.Errorf("error(checkSecondFactor): %w", ErrInvalidMFACode)
+	}
+	if err := s.repository.ResetTOTPFailures(ctx, user.ID); err != nil {
+		return fmt.Errorf("error(checkSecondFactor): %w", err)
+	}
+	return nil
+}
+
+// countMFAAttempt is called before a code is checked, so parallel requests
+// cannot race past the limit.
+func (s *Service) countMFAAttempt(ctx context.Context, userID string) error {
+	now := time.Now()
+	counted, err := s.repository.CountTOTPAttempt(ctx, userID, mfaMaxAttempts, now, now.Add(mfaLockout))
+	if err != nil {
+		return fmt.Errorf("error(countMFAAttempt): %w", err)
+	}
+	if !counted {
+		return fmt.Errorf("error(countMFAAttempt): %w", ErrMFALocked)
+	}
+	return nil
+}
+
+// verifySecondFactor checks a TOTP code, or else a recovery code, and burns
+// whichever was used.
+func (s *Service) verifySecondFactor(ctx context.Context, user *repository.User, code, recoveryCode string) (bool, error) {
+	if !user.TOTPEnabled {
+		return false, nil
+	}
+	if code != "" {
+		step, ok := verifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return s.repository.UseTOTPStep(ctx, user.ID, step)
+	}
+	if recoveryCode == "" {
+		return false, nil
+	}
+	normalized := strings.ToUpper(recoveryCodeReplacement.Replace(recoveryCode))
+	return s.repository.UseRecoveryCode(ctx, user.ID, hashVerifier(normalized))
+}
+
+// EnrollTOTP generates a new secret for the user owning accessToken. It does
+// not protect logins until ConfirmTOTP proves the authenticator app has it.
+func (s *Service) EnrollTOTP(ctx context.Context, accessToken string) (*TOTPEnrollment, error) {
+	user, err := s.tokenUser(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(EnrollTOTP): %w", err)
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("error(EnrollTOTP): %w", ErrMFAAlreadyEnabled)
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("error(EnrollTOTP): %w", err)
+	}
+	stored, err := s.repository.SetTOTPSecret(ctx, user.ID, secret)
+	if err != nil {
+		return nil, fmt.Errorf("error(EnrollTOTP): %w", err)
+	}
+	if !stored {
+		return nil, fmt.Errorf("error(EnrollTOTP): %w", ErrMFAAlreadyEnabled)
+	}
+	issuer := s.issuer
+	if u, err := url.Parse(s.issuer); err == nil && u.Host != "" {
+		issuer = u.Host
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totpURI(issuer, user.Email, secret)}, nil
+}
+
+// ConfirmTOTP enables TOTP once the user enters a valid code and returns the
+// recovery codes. They are stored hashed and cannot be shown again. Wrong
+// codes count towards the same lockout as logins.
+func (s *Service) ConfirmTOTP(ctx context.Context, accessToken, code string) ([]string, error) {
+	user, err := s.tokenUser(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", err)
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", ErrMFAAlreadyEnabled)
+	}
+	if user.TOTPSecret == "" {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", ErrMFANotEnrolled)
+	}
+	if err := s.countMFAAttempt(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", err)
+	}
+	step, ok := verifyTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", ErrInvalidMFACode)
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("error(ConfirmTOTP): %w", err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashVerifier(recoveryCodeReplacement.Replace(code)))
+	}
+	enabled, err := s.repository.EnableTOTP(ctx, user.ID, step, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", err)
+	}
+	if !enabled {
+		return nil, fmt.Errorf("error(ConfirmTOTP): %w", ErrMFAAlreadyEnabled)
+	}
+	return codes, nil
+}
+
+// DisableTOTP removes the second factor. It needs a current TOTP or recovery
+// code, so a stolen access token alone cannot downgrade the account.
+func (s *Service) DisableTOTP(ctx context.Context, accessToken, code, recoveryCode string) error {
+	user, err := s.tokenUser(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("error(DisableTOTP): %w", err)
+	}
+	if !user.TOTPEnabled {
+		return fmt.Errorf("error(DisableTOTP): %w", ErrMFANotEnrolled)
+	}
+	if err := s.checkSecondFactor(ctx, user, code, recoveryCode); err != nil {
+		return fmt.Errorf("error(DisableTOTP): %w", err)
+	}
+	if err := s.repository.DisableTOTP(ctx, user.ID); err != nil {
+		return fmt.Errorf("error(DisableTOTP): %w", err)
+	}
+	return nil
+}
+
+// tokenUser returns the account of the user owning accessToken. Users known
+// only by an id passed to /token have no account.
+func (s *Service) tokenUser(ctx context.Context, accessToken string) (*repository.User, error) {
+	claims, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(tokenUser): %w", err)
+	}
+	user, err := s.repository.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error(tokenUser): %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("error(tokenUser): %w", ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// generateRecoveryCode returns 80 random bits as XXXX-XXXX-XXXX-XXXX. That
+// much entropy makes a fast SHA-256 digest safe to store.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error(generateRecoveryCode): rand read failed: %w", err)
+	}
+	code := recoveryCodeEncoding.EncodeToString(b)
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
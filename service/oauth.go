@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"time"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// Introspect implements RFC 7662: any token that is unknown, expired, revoked
+// or malformed is reported as inactive rather than as an error.
+func (s *Service) Introspect(ctx context.Context, token, tokenTypeHint string) (models.IntrospectionResponse, error) {
+	lookups := []func(context.Context, string) (models.IntrospectionResponse, error){s.introspectAccessToken, s.introspectRefreshToken}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		resp, err := lookup(ctx, token)
+		if err != nil {
+			return models.IntrospectionResponse{}, fmt.Errorf("error(Introspect): %w", err)
+		}
+		if resp.Active {
+			return resp, nil
+		}
+	}
+	return models.IntrospectionResponse{Active: false}, nil
+}
+
+func (s *Service) introspectAccessToken(ctx context.Context, token string) (models.IntrospectionResponse, error) {
+	claims, err := s.parseIssuedAccessToken(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		return models.IntrospectionResponse{Active: false}, nil
+	}
+	if err != nil {
+		return models.IntrospectionResponse{}, err
+	}
+	resp := models.IntrospectionResponse{
+		Active:    true,
+		TokenType: TokenTypeHintAccessToken,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		ClientID:  claims.ClientID,
+		Scope:     claims.Scope,
+		Act:       claims.Act,
+		Exp:       claims.ExpiresAt.Unix(),
+	}
+	if claims.IssuedAt != nil {
+		resp.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		resp.Nbf = claims.NotBefore.Unix()
+	}
+	return resp, nil
+}
+
+func (s *Service) introspectRefreshToken(ctx context.Context, token string) (models.IntrospectionResponse, error) {
+	row, err := s.lookupRefreshToken(ctx, token)
+	if err != nil {
+		return models.IntrospectionResponse{}, err
+	}
+	if row == nil || row.Used || time.Now().After(row.ExpiresAt) {
+		return models.IntrospectionResponse{Active: false}, nil
+	}
+	return models.IntrospectionResponse{
+		Active:    true,
+		TokenType: TokenTypeHintRefreshToken,
+		Sub:       row.UserID,
+		ClientID:  row.ClientID,
+		Scope:     row.Scope,
+		Exp:       row.ExpiresAt.Unix(),
+		Iat:       row.LastUsedAt.Unix(),
+		Jti:       row.TokenID,
+	}, nil
+}
+
+// RevokeToken implements RFC 7009 for the authenticated clientID. Revoking a
+// refresh token ends the whole grant: every refresh token of its family and
+// the access tokens issued with them, as logout and reuse detection do.
+// Unknown or invalid tokens and tokens issued to another client are ignored
+// so the caller cannot use the endpoint to probe which tokens exist.
+func (s *Service) RevokeToken(ctx context.Context, clientID, token, tokenTypeHint string) error {
+	revokers := []func(context.Context, string, string) (bool, error){s.revokeAccessTokenString, s.revokeRefreshTokenString}
+	if tokenTypeHint != TokenTypeHintAccessToken {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+	for _, revoke := range revokers {
+		revoked, err := revoke(ctx, clientID, token)
+		if err != nil {
+			return fmt.Errorf("error(RevokeToken): %w", err)
+		}
+		if revoked {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *Service) revokeAccessTokenString(ctx context.Context, clientID, token string) (bool, error) {
+	claims, err := s.parseIssuedAccessToken(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if claims.ClientID != clientID {
+		return false, nil
+	}
+	return true, s.revokeAccessToken(ctx, claims)
+}
+
+func (s *Service) revokeRefreshTokenString(ctx context.Context, clientID, token string) (bool, error) {
+	row, err := s.lookupRefreshToken(ctx, token)
+	if err != nil || row == nil {
+		return false, err
+	}
+	if row.ClientID != clientID {
+		return false, nil
+	}
+	return true, s.revokeFamily(ctx, row.FamilyID)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/repository"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeOpenID = "openid"
+	ScopeEmail  = "email"
+)
+
+// OIDCEnabled reports whether the service acts as an OpenID Connect
+// provider. Relying parties verify id_tokens with the published JWKS; an
+// HMAC secret would have to be shared with every client, and any of them
+// could then forge id_tokens for the others.
+func (s *Service) OIDCEnabled() bool {
+	return s.keys.asymmetric()
+}
+
+// generateIDToken returns an OpenID Connect id_token when the session was
+// granted the openid scope, and "" otherwise.
+func (s *Service) generateIDToken(row *repository.RefreshToken, nonce string) (string, error) {
+	if !s.OIDCEnabled() || row.ClientID == "" || !slices.Contains(strings.Fields(row.Scope), ScopeOpenID) {
+		return "", nil
+	}
+	claims := &IDTokenClaims{
+		RegisteredClaims: s.registeredClaims(row.UserID, "", []string{row.ClientID}, row.AccessExpiresAt),
+		AuthTime:         row.CreatedAt.Unix(),
+		Nonce:            nonce,
+		AMR:              strings.Fields(row.AMR),
+		ACR:              acrFor(strings.Fields(row.AMR)),
+	}
+	token, err := s.keys.signer().sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error(generateIDToken): sign token: %w", err)
+	}
+	return token, nil
+}
+
+// UserInfo returns the claims about the user owning accessToken. The email
+// claims go only to the user's own sessions and to clients granted the
+// email scope.
+func (s *Service) UserInfo(ctx context.Context, accessToken string) (models.UserInfo, error) {
+	claims, err := s.parseUserToken(ctx, accessToken)
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("error(UserInfo): %w", err)
+	}
+	userID := claims.UserID
+	roles, permissions, err := s.repository.GetUserAuthorization(ctx, userID)
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("error(UserInfo): %w", err)
+	}
+	info := models.UserInfo{Sub: userID, Roles: roles, Permissions: permissions}
+	if !grantsEmail(claims) {
+		return info, nil
+	}
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("error(UserInfo): %w", err)
+	}
+	if user != nil {
+		info.Email = user.Email
+		info.EmailVerified = &user.EmailVerified
+	}
+	return info, nil
+}
+
+func grantsEmail(claims *AccessClaims) bool {
+	return claims.ClientID == "" || slices.Contains(strings.Fields(claims.Scope), ScopeEmail)
+}
+
+func (s *Service) OpenIDConfiguration() models.OpenIDConfiguration {
+	return models.OpenIDConfiguration{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + "/authorize",
+		TokenEndpoint:                     s.issuer + "/oauth/token",
+		UserinfoEndpoint:                  s.issuer + "/userinfo",
+		JWKSURI:                           s.issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:                s.issuer + "/revoke",
+		IntrospectionEndpoint:             s.issuer + "/introspect",
+		DeviceAuthorizationEndpoint:       s.issuer + "/device/code",
+		ScopesSupported:                   []string{ScopeOpenID, ScopeEmail},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               supportedGrantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  s.keys.validMethods(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nbf", "auth_time", "nonce", "amr", "acr", "email", "email_verified", "roles", "permissions"},
+	}
+}
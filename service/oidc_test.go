@@ -0,0 +1,66 @@
+package service
+
+import (
+	"crypto/elliptic"
+	"github.com/Tommych123/auth-service/repository"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOIDCRequiresAsymmetricKey(t *testing.T) {
+	privatePath, _ := newECKeyFiles(t, elliptic.P256())
+	tests := []struct {
+		name      string
+		algorithm string
+		secret    string
+		keyPath   string
+		enabled   bool
+	}{
+		{"hmac", "HS512", "secret", "", false},
+		{"ecdsa", "ES256", "", privatePath, true},
+	}
+	row := &repository.RefreshToken{UserID: "user", ClientID: "client", Scope: "openid email", AccessExpiresAt: time.Now().Add(time.Minute)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadKeyRing(tt.algorithm, tt.secret, tt.keyPath, "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &Service{keys: keys}
+			if s.OIDCEnabled() != tt.enabled {
+				t.Errorf("OIDCEnabled() = %v, want %v", s.OIDCEnabled(), tt.enabled)
+			}
+			idToken, err := s.generateIDToken(row, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (idToken != "") != tt.enabled {
+				t.Errorf("id_token issued = %v, want %v", idToken != "", tt.enabled)
+			}
+			if tt.enabled && slices.Contains(s.OpenIDConfiguration().IDTokenSigningAlgValuesSupported, "HS512") {
+				t.Error("discovery advertises HS512")
+			}
+		})
+	}
+}
+
+func TestGrantsEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims AccessClaims
+		want   bool
+	}{
+		{"own session", AccessClaims{UserID: "user"}, true},
+		{"client with email scope", AccessClaims{UserID: "user", ClientID: "client", Scope: "openid email"}, true},
+		{"client without email scope", AccessClaims{UserID: "user", ClientID: "client", Scope: "openid"}, false},
+		{"scope that only contains email", AccessClaims{UserID: "user", ClientID: "client", Scope: "emails"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grantsEmail(&tt.claims); got != tt.want {
+				t.Errorf("grantsEmail() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
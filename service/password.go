@@ -0,0 +1,105 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// passwordHasher hashes new passwords with the configured algorithm and
+// verifies hashes made by either algorithm, so switching PASSWORD_HASH or
+// raising the cost never locks anybody out.
+type passwordHasher struct {
+	algorithm  string
+	argon2     argon2Params
+	bcryptCost int
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+func (h passwordHasher) hash(password string) (string, error) {
+	if h.algorithm == PasswordHashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("error(hash): bcrypt: %w", err)
+		}
+		return string(hash), nil
+	}
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error(hash): rand read failed: %w", err)
+	}
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, argon2KeyLength)
+	b64 := base64.RawStdEncoding.EncodeToString
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.iterations, p.parallelism, b64(salt), b64(key)), nil
+}
+
+// verify reports whether password matches encoded and, if it does, whether
+// the hash should be replaced because the algorithm or its cost changed.
+func (h passwordHasher) verify(password, encoded string) (ok, rehash bool) {
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		p, salt, key, err := parseArgon2Hash(encoded)
+		if err != nil {
+			return false, false
+		}
+		computed := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+		return true, h.algorithm != PasswordHashArgon2id || p != h.argon2
+	}
+	if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+		return false, false
+	}
+	cost, _ := bcrypt.Cost([]byte(encoded))
+	return true, h.algorithm != PasswordHashBcrypt || cost != h.bcryptCost
+}
+
+// parseArgon2Hash reads the PHC string format
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func parseArgon2Hash(encoded string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, fmt.Errorf("error(parseArgon2Hash): malformed hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("error(parseArgon2Hash): unsupported version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("error(parseArgon2Hash): malformed parameters: %w", err)
+	}
+	if p.iterations == 0 || p.parallelism == 0 {
+		// argon2.IDKey panics on these.
+		return p, nil, nil, fmt.Errorf("error(parseArgon2Hash): malformed parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("error(parseArgon2Hash): malformed salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("error(parseArgon2Hash): malformed key")
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+var testArgon2 = argon2Params{memory: 64, iterations: 1, parallelism: 1}
+
+func TestParseArgon2Hash(t *testing.T) {
+	valid, err := passwordHasher{algorithm: PasswordHashArgon2id, argon2: testArgon2}.hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"too few fields", "$argon2id$v=19$m=64,t=1,p=1$" + parts[4], true},
+		{"too many fields", valid + "$extra", true},
+		{"other version", "$argon2id$v=16$" + strings.Join(parts[3:], "$"), true},
+		{"no version", "$argon2id$$" + strings.Join(parts[3:], "$"), true},
+		{"malformed parameters", "$argon2id$v=19$m=64;t=1;p=1$" + strings.Join(parts[4:], "$"), true},
+		{"zero iterations", "$argon2id$v=19$m=64,t=0,p=1$" + strings.Join(parts[4:], "$"), true},
+		{"zero parallelism", "$argon2id$v=19$m=64,t=1,p=0$" + strings.Join(parts[4:], "$"), true},
+		{"salt not base64", "$argon2id$v=19$m=64,t=1,p=1$!!$" + parts[5], true},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + parts[4] + "$", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, salt, key, err := parseArgon2Hash(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseArgon2Hash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (p != testArgon2 || len(salt) != argon2SaltLength || len(key) != argon2KeyLength) {
+				t.Errorf("parseArgon2Hash() = %+v, %d byte salt, %d byte key", p, len(salt), len(key))
+			}
+		})
+	}
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	current := passwordHasher{algorithm: PasswordHashArgon2id, argon2: testArgon2, bcryptCost: 10}
+	argonHash, err := current.hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := passwordHasher{algorithm: PasswordHashBcrypt, bcryptCost: 10}.hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger := current
+	stronger.argon2.iterations = 2
+	tests := []struct {
+		name       string
+		hasher     passwordHasher
+		password   string
+		encoded    string
+		wantOK     bool
+		wantRehash bool
+	}{
+		{"argon2id match", current, "secret", argonHash, true, false},
+		{"argon2id mismatch", current, "Secret", argonHash, false, false},
+		{"empty password", current, "", argonHash, false, false},
+		{"argon2id cost raised", stronger, "secret", argonHash, true, true},
+		{"bcrypt match migrates to argon2id", current, "secret", bcryptHash, true, true},
+		{"bcrypt mismatch", current, "wrong", bcryptHash, false, false},
+		{"malformed PHC string", current, "secret", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA", false, false},
+		{"zero parallelism", current, "secret", "$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHQ$a2V5a2V5", false, false},
+		{"empty hash", current, "secret", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := tt.hasher.verify(tt.password, tt.encoded)
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf("verify() = %v, %v, want %v, %v", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+}
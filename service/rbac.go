@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/repository"
+	"strings"
+)
+
+var (
+	ErrInvalidRole  = errors.New("invalid role")
+	ErrRoleNotFound = errors.New("role not found")
+)
+
+// SaveRole creates a role or replaces its permissions. Tokens already issued
+// keep the old permissions until they are refreshed.
+func (s *Service) SaveRole(ctx context.Context, name, description string, permissions []string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("error(SaveRole): invalid name %q: %w", name, ErrInvalidRole)
+	}
+	for _, permission := range permissions {
+		if permission == "" || strings.ContainsAny(permission, " \t\n") {
+			return fmt.Errorf("error(SaveRole): invalid permission %q: %w", permission, ErrInvalidRole)
+		}
+	}
+	role := &repository.Role{Name: name, Description: description, Permissions: permissions}
+	if err := s.repository.SaveRole(ctx, role); err != nil {
+		return fmt.Errorf("error(SaveRole): %w", err)
+	}
+	return nil
+}
+
+func (s *Service) ListRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := s.repository.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListRoles): %w", err)
+	}
+	roles := make([]models.Role, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, models.Role{Name: row.Name, Description: row.Description, Permissions: row.Permissions})
+	}
+	return roles, nil
+}
+
+func (s *Service) AssignRole(ctx context.Context, userID, role string) error {
+	assigned, err := s.repository.AssignRole(ctx, userID, role)
+	if err != nil {
+		return fmt.Errorf("error(AssignRole): %w", err)
+	}
+	if !assigned {
+		return fmt.Errorf("error(AssignRole): %w", ErrRoleNotFound)
+	}
+	return nil
+}
+
+func (s *Service) UnassignRole(ctx context.Context, userID, role string) error {
+	removed, err := s.repository.UnassignRole(ctx, userID, role)
+	if err != nil {
+		return fmt.Errorf("error(UnassignRole): %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("error(UnassignRole): %w", ErrRoleNotFound)
+	}
+	return nil
+}
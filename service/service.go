@@ -4,62 +4,235 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/pkg/mailer"
 	"github.com/Tommych123/auth-service/repository"
+	"github.com/Tommych123/auth-service/service/config"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventNewIP             = "new_ip"
+	EventSessionRevoked    = "session_revoked"
+)
+
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidGrant    = errors.New("invalid grant")
+)
+
 type Service struct {
 	repository *repository.Repository
-	jwtSecret  string
+	keys       *KeyRing
 	webhookURL string
+	lifetimes  tokenLifetimes
+	issuer     string
+	audience   string
+	leeway     time.Duration
+
+	impersonation impersonationPolicy
+
+	passwords         passwordHasher
+	dummyPasswordHash string
+
+	webAuthn *webauthn.WebAuthn
+
+	mailer mailer.Mailer
+	links  emailLinks
+
+	deviceVerificationURL string
+}
+
+type tokenLifetimes struct {
+	access     time.Duration
+	refresh    time.Duration
+	sessionMax time.Duration
 }
 
-func NewService(repository *repository.Repository, jwtSecret string, webhookURL string) *Service {
-	return &Service{
+func NewService(repository *repository.Repository, keys *KeyRing, mailer mailer.Mailer, cfg *config.Config) *Service {
+	s := &Service{
 		repository: repository,
-		jwtSecret:  jwtSecret,
-		webhookURL: webhookURL,
+		keys:       keys,
+		webhookURL: cfg.WebhookURL,
+		lifetimes: tokenLifetimes{
+			access:     cfg.AccessTokenTTL,
+			refresh:    cfg.RefreshTokenTTL,
+			sessionMax: cfg.SessionMaxLifetime,
+		},
+		issuer:   cfg.IssuerURL,
+		audience: cfg.JWTAudience,
+		leeway:   cfg.JWTLeeway,
+
+		impersonation: newImpersonationPolicy(cfg.ImpersonationPolicy),
+
+		passwords: passwordHasher{
+			algorithm: cfg.PasswordHash,
+			argon2: argon2Params{
+				memory:      cfg.Argon2MemoryKiB,
+				iterations:  cfg.Argon2Iterations,
+				parallelism: cfg.Argon2Parallelism,
+			},
+			bcryptCost: cfg.BcryptCost,
+		},
+
+		mailer: mailer,
+		links: emailLinks{
+			login:         emailLink{url: cfg.MagicLinkURL, ttl: cfg.MagicLinkTTL},
+			passwordReset: emailLink{url: cfg.PasswordResetURL, ttl: cfg.PasswordResetTTL},
+			verification:  emailLink{url: cfg.EmailVerificationURL, ttl: cfg.EmailVerificationTTL},
+		},
+
+		deviceVerificationURL: cfg.DeviceVerificationURL,
 	}
+	s.dummyPasswordHash, _ = s.passwords.hash("dummy password")
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+	})
+	if err != nil {
+		log.Fatalf("error(NewService):of webauthn config: %v", err)
+	}
+	s.webAuthn = webAuthn
+	return s
+}
+
+type TokenRequest struct {
+	UserID   string
+	ClientID string
+	Scope    string
+	Nonce    string
+	// AMR lists the authentication methods the user passed (RFC 8176); it
+	// stays with the session across refreshes.
+	AMR       []string
+	UserAgent string
+	IP        string
+	// FamilyID names the new session; a fresh one is made when it is empty.
+	FamilyID string
 }
 
-func (s *Service) GenerateTokens(ctx context.Context, userID, userAgent, ip string) (string, string, error) {
-	tokenID := uuid.New().String()
-	accessToken, err := s.generateAccessToken(userID, tokenID)
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresIn    time.Duration
+	Scope        string
+}
+
+// GenerateTokens signs the user in; amr lists the authentication methods
+// used, if the caller verified any.
+func (s *Service) GenerateTokens(ctx context.Context, userID, userAgent, ip string, amr ...string) (string, string, error) {
+	pair, err := s.IssueTokens(ctx, TokenRequest{UserID: userID, UserAgent: userAgent, IP: ip, AMR: amr})
 	if err != nil {
-		return "", "", fmt.Errorf("error(GenerateTokens): generate access token: %w", err)
+		return "", "", err
+	}
+	return pair.AccessToken, pair.RefreshToken, nil
+}
+
+// IssueTokens starts a new session. Every grant that signs a user in ends up
+// here, so session tracking is the same whichever way the user logged in.
+func (s *Service) IssueTokens(ctx context.Context, req TokenRequest) (*TokenPair, error) {
+	row := &repository.RefreshToken{
+		UserID:    req.UserID,
+		ClientID:  req.ClientID,
+		Scope:     req.Scope,
+		AMR:       strings.Join(req.AMR, " "),
+		UserAgent: req.UserAgent,
+		IPAddress: req.IP,
+		CreatedAt: time.Now(),
+		FamilyID:  req.FamilyID,
 	}
-	refreshToken, err := generateRandomBase64(32)
+	if row.FamilyID == "" {
+		row.FamilyID = uuid.New().String()
+	}
+	pair, err := s.newTokenPair(ctx, row)
 	if err != nil {
-		return "", "", fmt.Errorf("error(GenerateTokens): generate refresh token: %w", err)
+		return nil, fmt.Errorf("error(GenerateTokens): %w", err)
+	}
+	if pair.IDToken, err = s.generateIDToken(row, req.Nonce); err != nil {
+		return nil, fmt.Errorf("error(GenerateTokens): %w", err)
+	}
+	if err := s.repository.SaveRefreshToken(ctx, row); err != nil {
+		return nil, fmt.Errorf("error(GenerateTokens): save refresh token: %w", err)
 	}
-	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+
+	return pair, nil
+}
+
+// newTokenPair fills in the token fields of a session row and signs the
+// matching access token.
+func (s *Service) newTokenPair(ctx context.Context, row *repository.RefreshToken) (*TokenPair, error) {
+	lifetimes := s.lifetimes
+	if row.ClientID != "" {
+		client, err := s.repository.GetClient(ctx, row.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("error(newTokenPair): %w", err)
+		}
+		lifetimes = lifetimes.forClient(client)
+	}
+	row.TokenID = uuid.New().String()
+	row.AccessExpiresAt = time.Now().Add(lifetimes.access)
+	row.ExpiresAt = lifetimes.refreshExpiry(row.CreatedAt)
+	accessToken, err := s.generateAccessToken(ctx, row)
 	if err != nil {
-		return "", "", fmt.Errorf("error(GenerateTokens): hash refresh token: %w", err)
+		return nil, fmt.Errorf("error(newTokenPair): generate access token: %w", err)
 	}
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
-	if err := s.repository.SaveRefreshToken(ctx, userID, string(hashedToken), userAgent, ip, expiresAt, tokenID); err != nil {
-		return "", "", fmt.Errorf("error(GenerateTokens): save refresh token: %w", err)
+	refreshToken, selector, verifierHash, err := newSplitToken()
+	if err != nil {
+		return nil, fmt.Errorf("error(newTokenPair): generate refresh token: %w", err)
 	}
+	row.Selector = selector
+	row.TokenHash = verifierHash
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    lifetimes.access,
+		Scope:        row.Scope,
+	}, nil
+}
 
-	return accessToken, refreshToken, nil
+// refreshExpiry caps the sliding refresh lifetime by the absolute session
+// lifetime, so rotation cannot keep a session alive forever.
+func (l tokenLifetimes) refreshExpiry(sessionCreatedAt time.Time) time.Time {
+	expiresAt := time.Now().Add(l.refresh)
+	if l.sessionMax > 0 && sessionCreatedAt.Add(l.sessionMax).Before(expiresAt) {
+		return sessionCreatedAt.Add(l.sessionMax)
+	}
+	return expiresAt
 }
 
-func (s *Service) generateAccessToken(userID, tokenID string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"jti":     tokenID,
-		"exp":     time.Now().Add(10 * time.Minute).Unix(),
-		"iat":     time.Now().Unix(),
+// generateAccessToken embeds the user's current roles and permissions, so a
+// role change reaches the user's tokens on the next refresh.
+func (s *Service) generateAccessToken(ctx context.Context, row *repository.RefreshToken) (string, error) {
+	roles, permissions, err := s.repository.GetUserAuthorization(ctx, row.UserID)
+	if err != nil {
+		return "", fmt.Errorf("error(generateAccessToken): %w", err)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return s.keys.signer().sign(&AccessClaims{
+		RegisteredClaims: s.registeredClaims(row.UserID, row.TokenID, []string{s.audience}, row.AccessExpiresAt),
+		UserID:           row.UserID,
+		ClientID:         row.ClientID,
+		Scope:            row.Scope,
+		Roles:            roles,
+		Permissions:      permissions,
+		AMR:              strings.Fields(row.AMR),
+		ACR:              acrFor(strings.Fields(row.AMR)),
+	})
 }
 
 func generateRandomBase64(n int) (string, error) {
@@ -67,68 +240,359 @@ func generateRandomBase64(n int) (string, error) {
 	if _, err := rand.Read(b); err != nil {
 		return "", fmt.Errorf("error(generateRandomBase64): rand read failed: %w", err)
 	}
-	return base64.StdEncoding.EncodeToString(b), nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func (s *Service) GetUserIDFromToken(tokenStr string) (string, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("error(GetUserIDFromToken): unexpected signing method")
-		}
-		return []byte(s.jwtSecret), nil
-	})
+// newSplitToken returns a "selector.verifier" token. The selector is stored in
+// clear for an indexed lookup, the 256-bit verifier only as a SHA-256 digest:
+// with that much entropy a slow password hash buys nothing.
+func newSplitToken() (token, selector, verifierHash string, err error) {
+	selector, err = generateRandomBase64(12)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error(newSplitToken): %w", err)
+	}
+	verifier, err := generateRandomBase64(32)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error(newSplitToken): %w", err)
+	}
+	return selector + "." + verifier, selector, hashVerifier(verifier), nil
+}
+
+func parseSplitToken(token string) (selector, verifier string, ok bool) {
+	selector, verifier, ok = strings.Cut(token, ".")
+	return selector, verifier, ok && selector != "" && verifier != ""
+}
+
+func hashVerifier(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return hex.EncodeToString(sum[:])
+}
+
+func verifierMatches(verifier, verifierHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashVerifier(verifier)), []byte(verifierHash)) == 1
+}
+
+func (s *Service) GetUserIDFromToken(ctx context.Context, tokenStr string) (string, error) {
+	claims, err := s.parseUserToken(ctx, tokenStr)
+	if err != nil {
+		return "", fmt.Errorf("error(GetUserIDFromToken): %w", err)
+	}
+	return claims.UserID, nil
+}
+
+// parseUserToken is parseAccessToken for endpoints that act on behalf of a
+// user and so refuse tokens a client was issued for itself.
+func (s *Service) parseUserToken(ctx context.Context, tokenStr string) (*AccessClaims, error) {
+	claims, err := s.parseAccessToken(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" {
+		return nil, fmt.Errorf("error(parseUserToken): user_id not found in token: %w", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// parseSessionToken is parseUserToken for endpoints that start or manage the
+// user's own sessions and second factors. Exchanged tokens carrying act are
+// refused so that a delegate cannot mint fresh sessions without the act
+// marker or change the user's credentials.
+func (s *Service) parseSessionToken(ctx context.Context, tokenStr string) (*AccessClaims, error) {
+	claims, err := s.parseUserToken(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Act != nil {
+		return nil, fmt.Errorf("error(parseSessionToken): delegated token: %w", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// parseAccessToken accepts only tokens whose audience is this service.
+func (s *Service) parseAccessToken(ctx context.Context, tokenStr string) (*AccessClaims, error) {
+	return s.verifyAccessToken(ctx, tokenStr, jwt.WithAudience(s.audience))
+}
+
+// parseIssuedAccessToken accepts any unrevoked access token this service
+// issued, whatever its audience.
+func (s *Service) parseIssuedAccessToken(ctx context.Context, tokenStr string) (*AccessClaims, error) {
+	return s.verifyAccessToken(ctx, tokenStr)
+}
+
+func (s *Service) verifyAccessToken(ctx context.Context, tokenStr string, opts ...jwt.ParserOption) (*AccessClaims, error) {
+	var claims AccessClaims
+	token, err := jwt.ParseWithClaims(tokenStr, &claims, s.keys.keyFunc, append(s.parserOptions(), opts...)...)
 	if err != nil || !token.Valid {
-		return "", fmt.Errorf("error(GetUserIDFromToken): invalid token")
+		return nil, fmt.Errorf("error(parseAccessToken): %w", ErrInvalidToken)
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("error(GetUserIDFromToken): invalid token claims")
+	if claims.ID == "" {
+		return nil, fmt.Errorf("error(parseAccessToken): jti not found in token: %w", ErrInvalidToken)
 	}
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return "", fmt.Errorf("error(GetUserIDFromToken): user_id not found in token")
+	revoked, err := s.repository.IsAccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error(parseAccessToken): check denylist: %w", err)
 	}
-	return userID, nil
+	if revoked {
+		return nil, fmt.Errorf("error(parseAccessToken): token revoked: %w", ErrInvalidToken)
+	}
+	return &claims, nil
+}
+
+func (s *Service) revokeAccessToken(ctx context.Context, claims *AccessClaims) error {
+	return s.repository.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+func (s *Service) JWKS() models.JWKSet {
+	return s.keys.JWKS()
 }
 
 func (s *Service) RefreshTokens(ctx context.Context, oldRefreshToken, userID, userAgent, ip string) (string, string, error) {
-	tokens, err := s.repository.GetRefreshTokensByUser(ctx, userID)
+	pair, err := s.Refresh(ctx, oldRefreshToken, TokenRequest{UserID: userID, UserAgent: userAgent, IP: ip})
 	if err != nil {
-		return "", "", fmt.Errorf("error(RefreshTokens): get tokens failed: %w", err)
+		return "", "", err
 	}
-	var matchedToken *repository.RefreshToken
-	for _, token := range tokens {
-		if bcrypt.CompareHashAndPassword([]byte(token.TokenHash), []byte(oldRefreshToken)) == nil {
-			matchedToken = &token
-			break
+	return pair.AccessToken, pair.RefreshToken, nil
+}
+
+// Refresh rotates a refresh token. req.UserID, when set, must own the token;
+// req.ClientID must match the client the token was issued to and, when set,
+// allow the refresh_token grant.
+func (s *Service) Refresh(ctx context.Context, oldRefreshToken string, req TokenRequest) (*TokenPair, error) {
+	if req.ClientID != "" {
+		client, err := s.repository.GetClient(ctx, req.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("error(RefreshTokens): %w", err)
 		}
+		if client == nil {
+			return nil, fmt.Errorf("error(RefreshTokens): %w", ErrInvalidClient)
+		}
+		if !clientAllowsGrant(client, GrantRefreshToken) {
+			return nil, fmt.Errorf("error(RefreshTokens): %w", ErrUnauthorizedClient)
+		}
+	}
+	matchedToken, err := s.lookupRefreshToken(ctx, oldRefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(RefreshTokens): get token failed: %w", err)
+	}
+	if matchedToken == nil || (req.UserID != "" && matchedToken.UserID != req.UserID) {
+		return nil, fmt.Errorf("error(RefreshTokens): refresh token not found or invalid: %w", ErrInvalidGrant)
 	}
-	if matchedToken == nil {
-		return "", "", fmt.Errorf("error(RefreshTokens): refresh token not found or invalid")
+	if matchedToken.ClientID != "" && matchedToken.ClientID != req.ClientID {
+		return nil, fmt.Errorf("error(RefreshTokens): token issued to another client: %w", ErrInvalidGrant)
 	}
-	if matchedToken.Used || time.Now().After(matchedToken.ExpiresAt) {
-		go sendWebhookAlert(s.webhookURL, matchedToken.UserID, ip)
-		return "", "", fmt.Errorf("error(RefreshTokens): token expired or already used")
+	if matchedToken.Used {
+		return nil, s.handleRefreshReuse(ctx, matchedToken, req.IP)
 	}
-	if matchedToken.UserAgent != userAgent {
+	if time.Now().After(matchedToken.ExpiresAt) {
+		return nil, fmt.Errorf("error(RefreshTokens): token expired: %w", ErrInvalidGrant)
+	}
+	if matchedToken.UserAgent != req.UserAgent {
 		_ = s.repository.DeleteTokensByUserID(ctx, matchedToken.UserID)
-		return "", "", fmt.Errorf("error(RefreshTokens): user agent mismatch - logged out")
+		return nil, fmt.Errorf("error(RefreshTokens): user agent mismatch - logged out: %w", ErrInvalidGrant)
+	}
+	if matchedToken.IPAddress != req.IP {
+		s.emitSecurityEvent(EventNewIP, matchedToken.UserID, req.IP)
+	}
+	row := &repository.RefreshToken{
+		UserID:    matchedToken.UserID,
+		ClientID:  matchedToken.ClientID,
+		Scope:     matchedToken.Scope,
+		AMR:       matchedToken.AMR,
+		UserAgent: req.UserAgent,
+		IPAddress: req.IP,
+		CreatedAt: matchedToken.CreatedAt,
+		FamilyID:  matchedToken.FamilyID,
+	}
+	pair, err := s.newTokenPair(ctx, row)
+	if err != nil {
+		return nil, fmt.Errorf("error(RefreshTokens): %w", err)
 	}
-	if matchedToken.IPAddress != ip {
-		go sendWebhookAlert(s.webhookURL, matchedToken.UserID, ip)
+	if pair.IDToken, err = s.generateIDToken(row, ""); err != nil {
+		return nil, fmt.Errorf("error(RefreshTokens): %w", err)
 	}
-	if err := s.repository.MarkTokenUsed(ctx, matchedToken.TokenHash); err != nil {
-		return "", "", fmt.Errorf("error(RefreshTokens): failed to mark token used: %w", err)
+	err = s.repository.RotateRefreshToken(ctx, matchedToken.ID, row)
+	if errors.Is(err, repository.ErrTokenAlreadyUsed) {
+		return nil, s.handleRefreshReuse(ctx, matchedToken, req.IP)
 	}
-	return s.GenerateTokens(ctx, matchedToken.UserID, userAgent, ip)
+	if err != nil {
+		return nil, fmt.Errorf("error(RefreshTokens): failed to rotate token: %w", err)
+	}
+	return pair, nil
 }
 
-func (s *Service) Deauthorize(ctx context.Context, userID string) error {
+// lookupRefreshToken returns the row the token refers to, or nil when the
+// token is malformed or its verifier does not match.
+func (s *Service) lookupRefreshToken(ctx context.Context, token string) (*repository.RefreshToken, error) {
+	selector, verifier, ok := parseSplitToken(token)
+	if !ok {
+		return nil, nil
+	}
+	row, err := s.repository.GetRefreshTokenBySelector(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("error(lookupRefreshToken): %w", err)
+	}
+	if row == nil || !verifierMatches(verifier, row.TokenHash) {
+		return nil, nil
+	}
+	return row, nil
+}
+
+func (s *Service) handleRefreshReuse(ctx context.Context, token *repository.RefreshToken, ip string) error {
+	if err := s.revokeFamily(ctx, token.FamilyID); err != nil {
+		return fmt.Errorf("error(RefreshTokens): %w", err)
+	}
+	s.emitSecurityEvent(EventRefreshTokenReuse, token.UserID, ip)
+	return fmt.Errorf("error(RefreshTokens): token already used - session revoked: %w", ErrInvalidGrant)
+}
+
+// revokeFamily drops every refresh token descended from the same login and
+// denylists the access tokens still alive in that chain, so a replayed token
+// locks out both the thief and the victim until the user signs in again.
+func (s *Service) revokeFamily(ctx context.Context, familyID string) error {
+	tokens, err := s.repository.DeleteTokenFamily(ctx, familyID)
+	if err != nil {
+		return fmt.Errorf("error(revokeFamily): %w", err)
+	}
+	if err := s.denylistAccessTokens(ctx, tokens); err != nil {
+		return fmt.Errorf("error(revokeFamily): %w", err)
+	}
+	return nil
+}
+
+// denylistAccessTokens revokes every access token issued with the refresh
+// tokens that has not expired yet. Rotated rows count too: the access token
+// issued with them stays valid until its own exp.
+func (s *Service) denylistAccessTokens(ctx context.Context, tokens []repository.RefreshToken) error {
+	now := time.Now()
+	for _, token := range tokens {
+		if !token.AccessExpiresAt.After(now) {
+			continue
+		}
+		if err := s.repository.RevokeAccessToken(ctx, token.TokenID, token.AccessExpiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) ListSessions(ctx context.Context, accessToken string) ([]models.Session, error) {
+	claims, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListSessions): %w", err)
+	}
+	current, err := s.sessionFamily(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListSessions): %w", err)
+	}
+	tokens, err := s.repository.GetActiveSessions(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListSessions): %w", err)
+	}
+	sessions := make([]models.Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, models.Session{
+			ID:         token.FamilyID,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+			Current:    current != "" && token.FamilyID == current,
+		})
+	}
+	return sessions, nil
+}
+
+// sessionFamily returns the family of the refresh token issued together with
+// the access token, which stays the same across rotations, or "" when the
+// token belongs to no session.
+func (s *Service) sessionFamily(ctx context.Context, claims *AccessClaims) (string, error) {
+	row, err := s.repository.GetRefreshTokenByTokenID(ctx, claims.UserID, claims.ID)
+	if err != nil || row == nil {
+		return "", err
+	}
+	return row.FamilyID, nil
+}
+
+// RevokeSession ends one session of the token's user, e.g. a lost device.
+// sessionID is the family id reported by ListSessions.
+func (s *Service) RevokeSession(ctx context.Context, accessToken, sessionID, ip string) error {
+	claims, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("error(RevokeSession): %w", err)
+	}
+	userID := claims.UserID
+	session, err := s.repository.GetSessionByFamilyID(ctx, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("error(RevokeSession): %w", err)
+	}
+	if session == nil {
+		return fmt.Errorf("error(RevokeSession): %w", ErrSessionNotFound)
+	}
+	if err := s.revokeFamily(ctx, session.FamilyID); err != nil {
+		return fmt.Errorf("error(RevokeSession): %w", err)
+	}
+	s.emitSecurityEvent(EventSessionRevoked, userID, ip)
+	return nil
+}
+
+// Deauthorize ends the session the access token belongs to, including tokens
+// rotated after it, or every session of its user when allSessions is set.
+func (s *Service) Deauthorize(ctx context.Context, accessToken string, allSessions bool) error {
+	claims, err := s.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("error(Deauthorize): %w", err)
+	}
+	userID := claims.UserID
+	if userID == "" {
+		return fmt.Errorf("error(Deauthorize): user_id not found in token: %w", ErrInvalidToken)
+	}
+	if allSessions && claims.Act != nil {
+		return fmt.Errorf("error(Deauthorize): delegated token cannot end every session: %w", ErrInvalidToken)
+	}
+	if err := s.revokeAccessToken(ctx, claims); err != nil {
+		return fmt.Errorf("error(Deauthorize): %w", err)
+	}
+	if !allSessions {
+		familyID, err := s.sessionFamily(ctx, claims)
+		if err != nil {
+			return fmt.Errorf("error(Deauthorize): %w", err)
+		}
+		if familyID == "" {
+			return nil
+		}
+		if err := s.revokeFamily(ctx, familyID); err != nil {
+			return fmt.Errorf("error(Deauthorize): %w", err)
+		}
+		return nil
+	}
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		return fmt.Errorf("error(Deauthorize): %w", err)
+	}
+	return nil
+}
+
+// revokeAllSessions drops every refresh token of the user and denylists the
+// access tokens still alive in those sessions.
+func (s *Service) revokeAllSessions(ctx context.Context, userID string) error {
+	tokens, err := s.repository.GetRefreshTokensByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error(revokeAllSessions): get tokens failed: %w", err)
+	}
+	if err := s.denylistAccessTokens(ctx, tokens); err != nil {
+		return fmt.Errorf("error(revokeAllSessions): %w", err)
+	}
 	return s.repository.DeleteTokensByUserID(ctx, userID)
 }
 
-func sendWebhookAlert(webhookURL, userID, ip string) {
+func (s *Service) emitSecurityEvent(event, userID, ip string) {
+	log.Printf("security event %s: user_id=%s ip=%s", event, userID, ip)
+	go sendWebhookAlert(s.webhookURL, event, userID, ip)
+}
+
+func sendWebhookAlert(webhookURL, event, userID, ip string) {
 	payload := map[string]string{
+		"event":   event,
 		"user_id": userID,
 		"ip":      ip,
 	}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"github.com/Tommych123/auth-service/repository"
+	"testing"
+	"time"
+)
+
+func TestParseSplitToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		wantSelector string
+		wantVerifier string
+		wantOK       bool
+	}{
+		{"valid", "sel.ver", "sel", "ver", true},
+		{"verifier with dot", "sel.ver.more", "sel", "ver.more", true},
+		{"empty verifier", "sel.", "sel", "", false},
+		{"empty selector", ".ver", "", "ver", false},
+		{"no separator", "selver", "selver", "", false},
+		{"empty", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, verifier, ok := parseSplitToken(tt.token)
+			if ok != tt.wantOK || (ok && (selector != tt.wantSelector || verifier != tt.wantVerifier)) {
+				t.Errorf("parseSplitToken(%q) = %q, %q, %v", tt.token, selector, verifier, ok)
+			}
+		})
+	}
+}
+
+func TestVerifierMatches(t *testing.T) {
+	token, selector, verifierHash, err := newSplitToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedSelector, verifier, ok := parseSplitToken(token)
+	if !ok || parsedSelector != selector {
+		t.Fatalf("parseSplitToken(newSplitToken()) = %q, %v", parsedSelector, ok)
+	}
+	tests := []struct {
+		name     string
+		verifier string
+		hash     string
+		want     bool
+	}{
+		{"match", verifier, verifierHash, true},
+		{"other verifier", verifier[1:], verifierHash, false},
+		{"empty verifier", "", verifierHash, false},
+		{"empty hash", verifier, "", false},
+		{"hash given as verifier", verifierHash, verifierHash, false},
+		{"verifier given as hash", verifier, verifier, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifierMatches(tt.verifier, tt.hash); got != tt.want {
+				t.Errorf("verifierMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshExpiry(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now()
+	tests := []struct {
+		name      string
+		lifetimes tokenLifetimes
+		createdAt time.Time
+		want      time.Time
+	}{
+		{"no session limit", tokenLifetimes{refresh: 7 * day}, now.Add(-365 * day), now.Add(7 * day)},
+		{"limit far away", tokenLifetimes{refresh: 7 * day, sessionMax: 30 * day}, now, now.Add(7 * day)},
+		{"limit closer than refresh", tokenLifetimes{refresh: 7 * day, sessionMax: 30 * day}, now.Add(-29 * day), now.Add(day)},
+		{"limit already passed", tokenLifetimes{refresh: 7 * day, sessionMax: 30 * day}, now.Add(-31 * day), now.Add(-day)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.lifetimes.refreshExpiry(tt.createdAt)
+			if diff := got.Sub(tt.want); diff < -time.Second || diff > time.Second {
+				t.Errorf("refreshExpiry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenLifetimesForClient(t *testing.T) {
+	defaults := tokenLifetimes{access: 10 * time.Minute, refresh: 7 * 24 * time.Hour, sessionMax: 30 * 24 * time.Hour}
+	tests := []struct {
+		name   string
+		client *repository.OAuthClient
+		want   tokenLifetimes
+	}{
+		{"no client", nil, defaults},
+		{"no overrides", &repository.OAuthClient{}, defaults},
+		{"access override", &repository.OAuthClient{AccessTokenTTLSeconds: 60},
+			tokenLifetimes{access: time.Minute, refresh: defaults.refresh, sessionMax: defaults.sessionMax}},
+		{"refresh override", &repository.OAuthClient{RefreshTokenTTLSeconds: 3600},
+			tokenLifetimes{access: defaults.access, refresh: time.Hour, sessionMax: defaults.sessionMax}},
+		{"both overrides", &repository.OAuthClient{AccessTokenTTLSeconds: 1, RefreshTokenTTLSeconds: 2},
+			tokenLifetimes{access: time.Second, refresh: 2 * time.Second, sessionMax: defaults.sessionMax}},
+		{"negative values ignored", &repository.OAuthClient{AccessTokenTTLSeconds: -1, RefreshTokenTTLSeconds: -1}, defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaults.forClient(tt.client); got != tt.want {
+				t.Errorf("forClient() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
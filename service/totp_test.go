@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B, SHA-1 rows. The RFC prints 8 digits; a 6-digit code
+// is the same value modulo 10^6, i.e. its last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		if got := totpCode(rfc6238Key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	for _, tt := range rfc6238Vectors {
+		step, ok := verifyTOTP(secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("verifyTOTP(T=%d, %s) = %d, %v; want %d, true", tt.unix, tt.code, step, ok, tt.unix/totpPeriod)
+		}
+	}
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"previous step", secret, "287082", 59 + totpPeriod, 1, true},
+		{"next step", secret, "287082", 59 - totpPeriod, 1, true},
+		{"two steps late", secret, "287082", 59 + 2*totpPeriod, 0, false},
+		{"wrong code", secret, "287083", 59, 0, false},
+		{"eight digits", secret, "94287082", 59, 0, false},
+		{"short code", secret, "28708", 59, 0, false},
+		{"empty code", secret, "", 59, 0, false},
+		{"bad secret", "not base32!", "287082", 59, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := verifyTOTP(tt.secret, tt.code, time.Unix(tt.now, 0))
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("verifyTOTP = %d, %v; want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/repository"
+	"github.com/google/uuid"
+	"log"
+	"net/mail"
+	"strings"
+)
+
+const (
+	minPasswordLength = 8
+	// maxPasswordLength is bcrypt's input limit, applied to argon2id too so
+	// PASSWORD_HASH can be switched either way.
+	maxPasswordLength = 72
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrWeakPassword       = errors.New("password does not meet requirements")
+	ErrUserExists         = errors.New("user already exists")
+)
+
+func (s *Service) Register(ctx context.Context, email, password string) (string, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return "", fmt.Errorf("error(Register): %w", err)
+	}
+	if err := validatePassword(password); err != nil {
+		return "", fmt.Errorf("error(Register): %w", err)
+	}
+	hash, err := s.passwords.hash(password)
+	if err != nil {
+		return "", fmt.Errorf("error(Register): %w", err)
+	}
+	user := &repository.User{ID: uuid.New().String(), Email: email, PasswordHash: hash}
+	err = s.repository.CreateUser(ctx, user)
+	if errors.Is(err, repository.ErrUserExists) {
+		return "", fmt.Errorf("error(Register): %w", ErrUserExists)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error(Register): %w", err)
+	}
+	// The account works without it, so a failure here does not undo the
+	// registration; the user can ask for another link.
+	if s.mailer != nil {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("error(Register): send verification email: %v", err)
+		}
+	}
+	return user.ID, nil
+}
+
+// authenticatePassword returns the user with this email and password. A hash
+// made with outdated parameters is replaced on the fly.
+func (s *Service) authenticatePassword(ctx context.Context, email, password string) (*repository.User, error) {
+	user, err := s.repository.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return nil, fmt.Errorf("error(authenticatePassword): %w", err)
+	}
+	if user == nil {
+		// Spend the same time as for a wrong password so the response does
+		// not reveal which emails are registered.
+		s.passwords.verify(password, s.dummyPasswordHash)
+		return nil, fmt.Errorf("error(authenticatePassword): %w", ErrInvalidCredentials)
+	}
+	ok, rehash := s.passwords.verify(password, user.PasswordHash)
+	if !ok {
+		return nil, fmt.Errorf("error(authenticatePassword): %w", ErrInvalidCredentials)
+	}
+	if rehash {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+	return user, nil
+}
+
+// rehashPassword is best effort: the login already succeeded and the old
+// hash stays valid, so a failure is only logged.
+func (s *Service) rehashPassword(ctx context.Context, userID, password string) {
+	hash, err := s.passwords.hash(password)
+	if err == nil {
+		err = s.repository.UpdatePasswordHash(ctx, userID, hash)
+	}
+	if err != nil {
+		log.Printf("error(rehashPassword):user_id=%s %v", userID, err)
+	}
+}
+
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != strings.TrimSpace(email) {
+		return "", fmt.Errorf("error(normalizeEmail): %w", ErrInvalidEmail)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return fmt.Errorf("error(validatePassword): length must be %d-%d bytes: %w", minPasswordLength, maxPasswordLength, ErrWeakPassword)
+	}
+	return nil
+}
+
+// PasswordGrant implements the resource owner password credentials grant for
+// first-party clients. req.ClientID, when set, must allow the grant.
+func (s *Service) PasswordGrant(ctx context.Context, email, password string, req TokenRequest) (*TokenPair, error) {
+	if req.ClientID != "" {
+		client, err := s.repository.GetClient(ctx, req.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("error(PasswordGrant): %w", err)
+		}
+		if client == nil {
+			return nil, fmt.Errorf("error(PasswordGrant): %w", ErrInvalidClient)
+		}
+		if !clientAllowsGrant(client, GrantPassword) {
+			return nil, fmt.Errorf("error(PasswordGrant): %w", ErrUnauthorizedClient)
+		}
+		if req.Scope, err = resolveScope(client, req.Scope); err != nil {
+			return nil, fmt.Errorf("error(PasswordGrant): %w", err)
+		}
+	}
+	user, err := s.authenticatePassword(ctx, email, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		return nil, fmt.Errorf("error(PasswordGrant): %w: %w", err, ErrInvalidGrant)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error(PasswordGrant): %w", err)
+	}
+	// The grant has no way to ask for a second factor; such users sign in
+	// through /login or the authorization code flow.
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("error(PasswordGrant): %w", ErrMFARequired)
+	}
+	req.UserID = user.ID
+	req.AMR = []string{AMRPassword}
+	return s.IssueTokens(ctx, req)
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Tommych123/auth-service/models"
+	"github.com/Tommych123/auth-service/repository"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"io"
+	"slices"
+	"time"
+)
+
+const (
+	EventPasskeyCounterRegression = "passkey_counter_regression"
+	EventPasskeyRemoved           = "passkey_removed"
+
+	// AMRHardwareKey marks a proof of possession of a hardware-secured key
+	// (RFC 8176). A passkey with user verification is a factor on its own
+	// plus a PIN or biometric, so such logins also carry "mfa".
+	AMRHardwareKey = "hwk"
+)
+
+const (
+	ceremonyCreate  = "webauthn.create"
+	ceremonyGet     = "webauthn.get"
+	webAuthnTimeout = 5 * time.Minute
+
+	// passkeyStepUpMaxAge is how long after a sign-in with a second factor
+	// the session may still add or remove passkeys.
+	passkeyStepUpMaxAge = 10 * time.Minute
+)
+
+var (
+	ErrInvalidPasskey  = errors.New("invalid passkey")
+	ErrPasskeyExists   = errors.New("passkey already registered")
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrStepUpRequired  = errors.New("recent sign-in with a second factor required")
+)
+
+// passkeyUser adapts a user and their stored credentials to webauthn.User.
+// The user handle is the user id, so a discoverable login names the account
+// without a lookup by email.
+type passkeyUser struct {
+	id          string
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte                         { return []byte(u.id) }
+func (u *passkeyUser) WebAuthnName() string                       { return u.name }
+func (u *passkeyUser) WebAuthnDisplayName() string                { return u.name }
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// BeginPasskeyRegistration returns the options for creating a passkey for
+// the user owning accessToken. The session must have signed in with a
+// second factor within passkeyStepUpMaxAge.
+func (s *Service) BeginPasskeyRegistration(ctx context.Context, accessToken string) (*models.PublicKeyCredentialCreationOptions, error) {
+	user, err := s.stepUpUser(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(BeginPasskeyRegistration): %w", err)
+	}
+	existing, err := s.repository.ListWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error(BeginPasskeyRegistration): %w", err)
+	}
+	challenge, err := s.newWebAuthnChallenge(ctx, ceremonyCreate, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error(BeginPasskeyRegistration): %w", err)
+	}
+	params := make([]models.PublicKeyCredentialParameters, 0, len(webauthn.CredentialParametersDefault()))
+	for _, param := range webauthn.CredentialParametersDefault() {
+		params = append(params, models.PublicKeyCredentialParameters{Type: string(param.Type), Alg: int64(param.Algorithm)})
+	}
+	exclude := make([]models.PublicKeyCredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		exclude = append(exclude, models.PublicKeyCredentialDescriptor{Type: "public-key", ID: credential.ID})
+	}
+	return &models.PublicKeyCredentialCreationOptions{
+		Challenge: challenge,
+		RP:        models.RelyingParty{ID: s.webAuthn.Config.RPID, Name: s.webAuthn.Config.RPDisplayName},
+		User: models.PublicKeyCredentialUser{
+			ID:          base64.RawURLEncoding.EncodeToString([]byte(user.ID)),
+			Name:        user.Email,
+			DisplayName: user.Email,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            webAuthnTimeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: models.AuthenticatorSelection{
+			ResidentKey:        string(protocol.ResidentKeyRequirementRequired),
+			RequireResidentKey: true,
+			UserVerification:   string(protocol.VerificationRequired),
+		},
+		Attestation: string(protocol.PreferNoAttestation),
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the credential in body, the JSON of
+// navigator.credentials.create, and stores its public key.
+func (s *Service) FinishPasskeyRegistration(ctx context.Context, accessToken string, body io.Reader) (string, error) {
+	user, err := s.stepUpUser(ctx, accessToken)
+	if err != nil {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): %w", err)
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(body)
+	if err != nil {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): %v: %w", err, ErrInvalidPasskey)
+	}
+	challenge, err := s.consumeWebAuthnChallenge(ctx, parsed.Response.CollectedClientData, ceremonyCreate)
+	if err != nil {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): %w", err)
+	}
+	if challenge.UserID != user.ID {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): challenge issued to another user: %w", ErrInvalidPasskey)
+	}
+	credential, err := s.verifyPasskeyCreation(&passkeyUser{id: user.ID, name: user.Email}, parsed)
+	if err != nil {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): %w", err)
+	}
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	err = s.repository.CreateWebAuthnCredential(ctx, &repository.WebAuthnCredential{
+		ID:             credentialID,
+		UserID:         user.ID,
+		PublicKey:      credential.PublicKey,
+		SignCount:      int64(credential.Authenticator.SignCount),
+		BackupEligible: credential.Flags.BackupEligible,
+	})
+	if errors.Is(err, repository.ErrCredentialExists) {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): %w", ErrPasskeyExists)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error(FinishPasskeyRegistration): %w", err)
+	}
+	return credentialID, nil
+}
+
+// BeginPasskeyLogin returns the options for signing in with a discoverable
+// passkey: the browser lets the user pick any passkey it holds for this
+// site. allowCredentials is always empty, so the options reveal neither
+// which accounts exist nor their credential ids.
+func (s *Service) BeginPasskeyLogin(ctx context.Context) (*models.PublicKeyCredentialRequestOptions, error) {
+	challenge, err := s.newWebAuthnChallenge(ctx, ceremonyGet, "")
+	if err != nil {
+		return nil, fmt.Errorf("error(BeginPasskeyLogin): %w", err)
+	}
+	return &models.PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          webAuthnTimeout.Milliseconds(),
+		RPID:             s.webAuthn.Config.RPID,
+		AllowCredentials: []models.PublicKeyCredentialDescriptor{},
+		UserVerification: string(protocol.VerificationRequired),
+	}, nil
+}
+
+// FinishPasskeyLogin verifies the assertion in body, the JSON of
+// navigator.credentials.get, and signs the user in. A signature counter
+// that fails to grow means the authenticator may have been cloned: the
+// login is refused and a security event is raised.
+func (s *Service) FinishPasskeyLogin(ctx context.Context, body io.Reader, userAgent, ip string) (string, string, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): %v: %w", err, ErrInvalidPasskey)
+	}
+	if _, err := s.consumeWebAuthnChallenge(ctx, parsed.Response.CollectedClientData, ceremonyGet); err != nil {
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): %w", err)
+	}
+	stored, err := s.repository.GetWebAuthnCredential(ctx, base64.RawURLEncoding.EncodeToString(parsed.RawID))
+	if err != nil {
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): %w", err)
+	}
+	if stored == nil {
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): unknown credential: %w", ErrInvalidPasskey)
+	}
+	if _, err := s.verifyPasskeyAssertion(stored, parsed); err != nil {
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): %w", err)
+	}
+	signCount := parsed.Response.AuthenticatorData.Counter
+	counted, err := s.repository.UpdateWebAuthnSignCount(ctx, stored.ID, int64(signCount))
+	if err != nil {
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): %w", err)
+	}
+	if !counted {
+		s.emitSecurityEvent(EventPasskeyCounterRegression, stored.UserID, ip)
+		return "", "", fmt.Errorf("error(FinishPasskeyLogin): sign counter %d did not grow past %d: %w",
+			signCount, stored.SignCount, ErrInvalidPasskey)
+	}
+	return s.GenerateTokens(ctx, stored.UserID, userAgent, ip, AMRHardwareKey, AMRMFA)
+}
+
+// ListPasskeys returns the passkeys of the user owning accessToken.
+func (s *Service) ListPasskeys(ctx context.Context, accessToken string) ([]models.Passkey, error) {
+	claims, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListPasskeys): %w", err)
+	}
+	credentials, err := s.repository.ListWebAuthnCredentials(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error(ListPasskeys): %w", err)
+	}
+	passkeys := make([]models.Passkey, 0, len(credentials))
+	for _, credential := range credentials {
+		passkey := models.Passkey{ID: credential.ID, CreatedAt: credential.CreatedAt}
+		if credential.LastUsedAt.Valid {
+			passkey.LastUsedAt = &credential.LastUsedAt.Time
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, nil
+}
+
+// DeletePasskey removes a passkey of the user owning accessToken, e.g. a
+// lost authenticator. Like registration it needs a recent second factor.
+func (s *Service) DeletePasskey(ctx context.Context, accessToken, credentialID, ip string) error {
+	user, err := s.stepUpUser(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("error(DeletePasskey): %w", err)
+	}
+	deleted, err := s.repository.DeleteWebAuthnCredential(ctx, user.ID, credentialID)
+	if err != nil {
+		return fmt.Errorf("error(DeletePasskey): %w", err)
+	}
+	if !deleted {
+		return fmt.Errorf("error(DeletePasskey): %w", ErrPasskeyNotFound)
+	}
+	s.emitSecurityEvent(EventPasskeyRemoved, user.ID, ip)
+	return nil
+}
+
+// stepUpUser is tokenUser for changes to the user's passkeys. A bare access
+// token is not enough: its session must have passed a second factor, and
+// recently, so that a stolen token cannot enroll an attacker's key.
+func (s *Service) stepUpUser(ctx context.Context, accessToken string) (*repository.User, error) {
+	claims, err := s.parseSessionToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error(stepUpUser): %w", err)
+	}
+	if !slices.Contains(claims.AMR, AMRMFA) {
+		return nil, fmt.Errorf("error(stepUpUser): session has no second factor: %w", ErrStepUpRequired)
+	}
+	session, err := s.repository.GetRefreshTokenByTokenID(ctx, claims.UserID, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error(stepUpUser): %w", err)
+	}
+	if session == nil || time.Since(session.CreatedAt) > passkeyStepUpMaxAge {
+		return nil, fmt.Errorf("error(stepUpUser): sign-in is not recent: %w", ErrStepUpRequired)
+	}
+	user, err := s.repository.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error(stepUpUser): %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("error(stepUpUser): %w", ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// verifyPasskeyCreation checks a registration response against the
+// challenge it answers: origin, rp id hash, user presence and verification,
+// the attestation statement and the key algorithm.
+func (s *Service) verifyPasskeyCreation(user *passkeyUser, parsed *protocol.ParsedCredentialCreationData) (*webauthn.Credential, error) {
+	session := s.passkeySession(parsed.Response.CollectedClientData.Challenge, user.id)
+	credential, err := s.webAuthn.CreateCredential(user, session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("error(verifyPasskeyCreation): %v: %w", err, ErrInvalidPasskey)
+	}
+	return credential, nil
+}
+
+// verifyPasskeyAssertion checks a login response against the stored
+// credential: origin, rp id hash, user presence and verification, the user
+// handle and the signature. The counter is left to the caller, which
+// stores it atomically.
+func (s *Service) verifyPasskeyAssertion(stored *repository.WebAuthnCredential, parsed *protocol.ParsedCredentialAssertionData) (*webauthn.Credential, error) {
+	credentialID, err := base64.RawURLEncoding.DecodeString(stored.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error(verifyPasskeyAssertion): stored credential id: %w", err)
+	}
+	owner := func(_, _ []byte) (webauthn.User, error) {
+		return &passkeyUser{
+			id: stored.UserID,
+			credentials: []webauthn.Credential{{
+				ID:            credentialID,
+				PublicKey:     stored.PublicKey,
+				Flags:         webauthn.CredentialFlags{BackupEligible: stored.BackupEligible},
+				Authenticator: webauthn.Authenticator{SignCount: uint32(stored.SignCount)},
+			}},
+		}, nil
+	}
+	session := s.passkeySession(parsed.Response.CollectedClientData.Challenge, "")
+	_, credential, err := s.webAuthn.ValidatePasskeyLogin(owner, session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("error(verifyPasskeyAssertion): %v: %w", err, ErrInvalidPasskey)
+	}
+	return credential, nil
+}
+
+// passkeySession is the webauthn session for a challenge that has already
+// been consumed; the challenge store keeps only its digest, so the value
+// comes from the client data it was checked against.
+func (s *Service) passkeySession(challenge, userID string) webauthn.SessionData {
+	session := webauthn.SessionData{
+		Challenge:        challenge,
+		RelyingPartyID:   s.webAuthn.Config.RPID,
+		UserVerification: protocol.VerificationRequired,
+		CredParams:       webauthn.CredentialParametersDefault(),
+	}
+	if userID != "" {
+		session.UserID = []byte(userID)
+	}
+	return session
+}
+
+// newWebAuthnChallenge returns a fresh challenge for the ceremony. Only its
+// digest is stored; the browser echoes the challenge back in client data.
+func (s *Service) newWebAuthnChallenge(ctx context.Context, ceremony, userID string) (string, error) {
+	challenge, err := generateRandomBase64(32)
+	if err != nil {
+		return "", fmt.Errorf("error(newWebAuthnChallenge): %w", err)
+	}
+	err = s.repository.SaveWebAuthnChallenge(ctx, &repository.WebAuthnChallenge{
+		ChallengeHash: hashVerifier(challenge),
+		Ceremony:      ceremony,
+		UserID:        userID,
+		ExpiresAt:     time.Now().Add(webAuthnTimeout),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error(newWebAuthnChallenge): %w", err)
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge marks the challenge the client data answers used.
+// Pages embedding this site in a frame are refused.
+func (s *Service) consumeWebAuthnChallenge(ctx context.Context, data protocol.CollectedClientData, ceremony string) (*repository.WebAuthnChallenge, error) {
+	if data.CrossOrigin {
+		return nil, fmt.Errorf("error(consumeWebAuthnChallenge): cross-origin ceremony: %w", ErrInvalidPasskey)
+	}
+	challenge, err := s.repository.ConsumeWebAuthnChallenge(ctx, hashVerifier(data.Challenge), ceremony)
+	if err != nil {
+		return nil, fmt.Errorf("error(consumeWebAuthnChallenge): %w", err)
+	}
+	if challenge == nil {
+		return nil, fmt.Errorf("error(consumeWebAuthnChallenge): challenge unknown, used or expired: %w", ErrInvalidPasskey)
+	}
+	return challenge, nil
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/Tommych123/auth-service/repository"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"slices"
+	"testing"
+)
+
+const (
+	testRPID      = "example.com"
+	testOrigin    = "https://example.com"
+	testChallenge = "dGVzdC1jaGFsbGVuZ2UtdGhhdC1pcy1sb25nLWVub3VnaA"
+
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagAttested       = 0x40
+)
+
+func testWebAuthnService(t *testing.T) *Service {
+	t.Helper()
+	w, err := webauthn.New(&webauthn.Config{RPID: testRPID, RPDisplayName: "Test", RPOrigins: []string{testOrigin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Service{webAuthn: w}
+}
+
+// testAuthenticator is a software passkey with a P-256 key that produces
+// "none" attestations and assertions the way a browser passes them on.
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userID       string
+}
+
+func newTestAuthenticator(t *testing.T, userID string) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{key: key, credentialID: []byte("passkey-" + userID), userID: userID}
+}
+
+func (a *testAuthenticator) coseKey(t *testing.T) []byte {
+	t.Helper()
+	pub, err := a.key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := pub.Bytes()
+	key, err := webauthncbor.Marshal(map[int]any{1: 2, 3: -7, -1: 1, -2: point[1:33], -3: point[33:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func (a *testAuthenticator) authData(t *testing.T, rpID string, flags byte, counter uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := binary.BigEndian.AppendUint32(append(rpIDHash[:], flags), counter)
+	if flags&flagAttested != 0 {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(append(data, a.credentialID...), a.coseKey(t)...)
+	}
+	return data
+}
+
+type ceremonyOptions struct {
+	ceremony string
+	origin   string
+	rpID     string
+	flags    byte
+	// userHandle and sign override the assertion's user handle and the
+	// bytes it signs.
+	userHandle string
+	sign       []byte
+}
+
+func (o ceremonyOptions) clientData(t *testing.T) []byte {
+	data, err := json.Marshal(map[string]any{"type": o.ceremony, "challenge": testChallenge, "origin": o.origin, "crossOrigin": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *testAuthenticator) create(t *testing.T, o ceremonyOptions) *protocol.ParsedCredentialCreationData {
+	t.Helper()
+	object, err := webauthncbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": a.authData(t, o.rpID, o.flags|flagAttested, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(o.clientData(t)),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(object),
+		},
+	})
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func (a *testAuthenticator) get(t *testing.T, o ceremonyOptions, counter uint32) *protocol.ParsedCredentialAssertionData {
+	t.Helper()
+	authData := a.authData(t, o.rpID, o.flags, counter)
+	clientData := o.clientData(t)
+	clientDataHash := sha256.Sum256(clientData)
+	signed := slices.Concat(authData, clientDataHash[:])
+	if o.sign != nil {
+		signed = o.sign
+	}
+	digest := sha256.Sum256(signed)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	userHandle := a.userID
+	if o.userHandle != "" {
+		userHandle = o.userHandle
+	}
+	body, _ := json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString([]byte(userHandle)),
+		},
+	})
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestVerifyPasskeyCreation(t *testing.T) {
+	valid := ceremonyOptions{ceremony: ceremonyCreate, origin: testOrigin, rpID: testRPID, flags: flagUserPresent | flagUserVerified}
+	with := func(change func(*ceremonyOptions)) ceremonyOptions {
+		o := valid
+		change(&o)
+		return o
+	}
+	tests := []struct {
+		name    string
+		options ceremonyOptions
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"backup eligible", with(func(o *ceremonyOptions) { o.flags |= flagBackupEligible }), false},
+		{"assertion client data", with(func(o *ceremonyOptions) { o.ceremony = ceremonyGet }), true},
+		{"other origin", with(func(o *ceremonyOptions) { o.origin = "https://evil.example" }), true},
+		{"other rp", with(func(o *ceremonyOptions) { o.rpID = "evil.example" }), true},
+		{"user not verified", with(func(o *ceremonyOptions) { o.flags = flagUserPresent }), true},
+		{"user not present", with(func(o *ceremonyOptions) { o.flags = flagUserVerified }), true},
+	}
+	s := testWebAuthnService(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t, "user-1")
+			credential, err := s.verifyPasskeyCreation(&passkeyUser{id: "user-1", name: "user@example.com"}, authenticator.create(t, tt.options))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPasskey) {
+					t.Fatalf("verifyPasskeyCreation = %v, want ErrInvalidPasskey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyPasskeyCreation: %v", err)
+			}
+			if !bytes.Equal(credential.ID, authenticator.credentialID) || !bytes.Equal(credential.PublicKey, authenticator.coseKey(t)) {
+				t.Errorf("credential = %x, %x", credential.ID, credential.PublicKey)
+			}
+			if credential.Flags.BackupEligible != (tt.options.flags&flagBackupEligible != 0) {
+				t.Errorf("backup eligible = %v", credential.Flags.BackupEligible)
+			}
+		})
+	}
+}
+
+func TestVerifyPasskeyAssertion(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "user-1")
+	stored := repository.WebAuthnCredential{
+		ID:        base64.RawURLEncoding.EncodeToString(authenticator.credentialID),
+		UserID:    "user-1",
+		PublicKey: authenticator.coseKey(t),
+		SignCount: 3,
+	}
+	valid := ceremonyOptions{ceremony: ceremonyGet, origin: testOrigin, rpID: testRPID, flags: flagUserPresent | flagUserVerified}
+	with := func(change func(*ceremonyOptions)) ceremonyOptions {
+		o := valid
+		change(&o)
+		return o
+	}
+	tests := []struct {
+		name    string
+		stored  func(c *repository.WebAuthnCredential)
+		options ceremonyOptions
+		wantErr bool
+	}{
+		{"valid", nil, valid, false},
+		{"registration client data", nil, with(func(o *ceremonyOptions) { o.ceremony = ceremonyCreate }), true},
+		{"other origin", nil, with(func(o *ceremonyOptions) { o.origin = "https://evil.example" }), true},
+		{"other rp", nil, with(func(o *ceremonyOptions) { o.rpID = "evil.example" }), true},
+		{"user not verified", nil, with(func(o *ceremonyOptions) { o.flags = flagUserPresent }), true},
+		{"other user handle", nil, with(func(o *ceremonyOptions) { o.userHandle = "user-2" }), true},
+		{"bad signature", nil, with(func(o *ceremonyOptions) { o.sign = []byte("something else") }), true},
+		{"credential of another user", func(c *repository.WebAuthnCredential) { c.UserID = "user-2" }, valid, true},
+		{"other key", func(c *repository.WebAuthnCredential) { c.PublicKey = newTestAuthenticator(t, "user-1").coseKey(t) }, valid, true},
+		{"backup eligibility changed", func(c *repository.WebAuthnCredential) { c.BackupEligible = true }, valid, true},
+	}
+	s := testWebAuthnService(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential := stored
+			if tt.stored != nil {
+				tt.stored(&credential)
+			}
+			_, err := s.verifyPasskeyAssertion(&credential, authenticator.get(t, tt.options, 4))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPasskey) {
+					t.Fatalf("verifyPasskeyAssertion = %v, want ErrInvalidPasskey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyPasskeyAssertion: %v", err)
+			}
+		})
+	}
+}